@@ -0,0 +1,76 @@
+package cors
+
+import (
+	"context"
+	"net/http"
+)
+
+type preflightKey struct{}
+
+type preflightState struct {
+	cors     *Cors
+	decision Decision
+}
+
+// corsResponseHeaders 是预检请求的响应中由CORS中间件添加的头部
+var corsResponseHeaders = []string{
+	"Access-Control-Allow-Origin",
+	"Access-Control-Allow-Methods",
+	"Access-Control-Allow-Headers",
+	"Access-Control-Allow-Credentials",
+	"Access-Control-Allow-Private-Network",
+	"Access-Control-Max-Age",
+}
+
+func withPreflight(r *http.Request, c *Cors, d Decision) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), preflightKey{}, &preflightState{c, d}))
+}
+
+// PreflightDecision 返回透传模式（OptionsPassthrough）下CORS中间件对预检请求的判定结果。
+// 请求不是透传的预检请求时返回false
+func PreflightDecision(r *http.Request) (Decision, bool) {
+	st, ok := r.Context().Value(preflightKey{}).(*preflightState)
+	if !ok {
+		return Decision{}, false
+	}
+	return st.decision, true
+}
+
+// FinalizePreflight 按照CORS中间件的配置结束一个透传的预检请求，写入配置的状态码以及相关头部。
+// 请求不是透传的预检请求时返回false并且不写入任何内容
+func FinalizePreflight(w http.ResponseWriter, r *http.Request) bool {
+	st, ok := r.Context().Value(preflightKey{}).(*preflightState)
+	if !ok {
+		return false
+	}
+	st.cors.terminatePreflight(w, st.decision)
+	return true
+}
+
+// VetoPreflight 否决一个透传的预检请求：移除CORS中间件已经添加的响应头部，
+// 并按照RejectMode和OnReject的配置响应，未配置拒绝响应时按照FinalizePreflight结束预检请求。
+// 请求不是透传的预检请求时返回false并且不写入任何内容
+func VetoPreflight(w http.ResponseWriter, r *http.Request, reason Reason) bool {
+	st, ok := r.Context().Value(preflightKey{}).(*preflightState)
+	if !ok {
+		return false
+	}
+	c := st.cors
+
+	headers := w.Header()
+	for _, name := range corsResponseHeaders {
+		headers.Del(name)
+	}
+	d := st.decision
+	d.Allowed = false
+	d.Reason = reason
+	c.logf("    Preflight vetoed by handler: %s", reason)
+
+	if d.isViolation() {
+		c.notify(r, d)
+	}
+	if !c.reject(w, r, d) {
+		c.terminatePreflight(w, d)
+	}
+	return true
+}
@@ -0,0 +1,84 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestPreflightDecision(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:     []string{"http://foobar.com"},
+		OptionsPassthrough: true,
+		PreflightStatus:    http.StatusNoContent,
+	})
+
+	var got Decision
+	var found bool
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, found = PreflightDecision(r)
+		assert.True(t, FinalizePreflight(w, r))
+	}))
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, newPreflight("http://foobar.com", "GET"))
+	assert.True(t, found)
+	assert.True(t, got.Allowed)
+	assert.True(t, got.Preflight)
+	assert.DeepEqual(t, res.Code, http.StatusNoContent)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "http://foobar.com")
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, newPreflight("http://barbaz.com", "GET"))
+	assert.True(t, found)
+	assert.False(t, got.Allowed)
+	assert.DeepEqual(t, got.Reason, ReasonOriginNotAllowed)
+}
+
+func TestVetoPreflight(t *testing.T) {
+	var violations []Violation
+	s := New(Options{
+		AllowedOrigins:     []string{"http://foobar.com"},
+		OptionsPassthrough: true,
+		RejectMode:         RejectForbidden,
+		OnViolation: func(v Violation) {
+			violations = append(violations, v)
+		},
+	})
+	h := s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, VetoPreflight(w, r, ReasonVetoed))
+	}))
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, newPreflight("http://foobar.com", "GET"))
+	assert.DeepEqual(t, res.Code, http.StatusForbidden)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "")
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Methods"), "")
+	assert.Len(t, violations, 1)
+	assert.DeepEqual(t, violations[0].Decision.Reason, ReasonVetoed)
+}
+
+func TestVetoPreflightWithoutRejectMode(t *testing.T) {
+	s := New(Options{OptionsPassthrough: true})
+	next := func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, VetoPreflight(w, r, ReasonVetoed))
+	}
+
+	res := httptest.NewRecorder()
+	s.ServeHTTP(res, newPreflight("http://foobar.com", "GET"), next)
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestPreflightHelpersOutsidePassthrough(t *testing.T) {
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	res := httptest.NewRecorder()
+
+	_, ok := PreflightDecision(req)
+	assert.False(t, ok)
+	assert.False(t, FinalizePreflight(res, req))
+	assert.False(t, VetoPreflight(res, req, ReasonVetoed))
+	assert.DeepEqual(t, res.Header(), http.Header{})
+}
@@ -2,6 +2,7 @@ package cors
 
 import (
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -19,14 +20,22 @@ type Options struct {
 	AllowOriginFunc func(origin string) bool
 
 	// AllowedMethods 是客户端允许使用的HTTP Method.
-	// 默认值就是简单方法：HEAD, GET, POST
+	// 默认值就是简单方法：HEAD, GET, POST。如果值为"*"表示所有方法都可以允许。
+	// 按照Fetch规范，只有DELETE, GET, HEAD, OPTIONS, POST, PUT不区分大小写，其他方法（如PATCH）区分大小写。
+	// 旧版本会将所有方法转换为大写，因此配置为"patch"时可以匹配浏览器发送的PATCH，现在不再匹配，
+	// New会在调试日志中对这样的方法给出警告，需要旧的行为时开启LenientMethods
 	AllowedMethods []string
 
 	// AllowedHeaders 定义了跨域请求可以允许的非标准头部
-	// 如果值为"*"表示所有头部都可以允许
+	// 如果值为"*"表示除Authorization之外的所有头部都可以允许，按照Fetch规范Authorization必须被明确列出。
+	// 包含"*"的值（例如"X-Amz-*"）表示匹配该模式的头部
 	AllowedHeaders []string
 
+	// DeniedHeaders 定义了跨域请求不允许的头部，支持与AllowedHeaders相同的模式，优先于AllowedHeaders（包括"*"）
+	DeniedHeaders []string
+
 	// ExposedHeaders 指定哪些响应头部可以安全得暴露客户端
+	// 如果值为"*"并且不允许携带用户凭证，则暴露所有响应头部；携带凭证时"*"将被忽略
 	ExposedHeaders []string
 
 	// MaxAge 指定预检请求结果的最大缓存时间
@@ -36,9 +45,131 @@ type Options struct {
 	AllowCredentials bool
 
 	// OptionsPassthrough 指示让其他潜在的处理程序来处理OPTIONS请求
-	// 如果您的应用程序将自己处理OPTIONS请求，请将该开关打开
+	// 如果您的应用程序将自己处理OPTIONS请求，请将该开关打开。
+	// 后续的处理程序可以通过PreflightDecision获取预检请求的判定结果，并通过FinalizePreflight或VetoPreflight结束预检请求
 	OptionsPassthrough bool
 
+	// ReportOnly 开启仅报告模式。
+	// 在该模式下策略仍然会对每个请求做出严格的判定，并将会被拒绝的请求报告给OnViolation，
+	// 响应则完全由LivePolicy生成，从而可以在不影响现有客户端的情况下安全地收紧策略
+	ReportOnly bool
+
+	// LivePolicy 是仅报告模式下实际生效的策略，只在ReportOnly开启时使用。
+	// 为nil时使用允许所有源、方法和头部但不允许携带用户凭证的宽松策略
+	LivePolicy *Cors
+
+	// OnViolation 在跨域请求被策略拒绝（或在仅报告模式下将会被拒绝）时调用
+	OnViolation func(v Violation)
+
+	// RejectMode 决定如何响应被拒绝的预检请求，默认保持以没有CORS头部的200响应
+	RejectMode RejectMode
+
+	// OnReject 用于自定义被拒绝的预检请求以及严格模式下被拒绝的实际请求的响应，设置后RejectMode将被忽略
+	OnReject func(w http.ResponseWriter, r *http.Request, d Decision)
+
+	// RejectDiagnostics 在拒绝响应中添加描述拒绝原因的诊断头部，仅建议在非生产环境中开启
+	RejectDiagnostics bool
+
+	// BlockDisallowed 开启严格模式，来自不被允许的源的非安全方法请求将被拒绝，不会到达后续的处理程序。
+	// CORS只能阻止浏览器读取响应，而不能阻止简单请求被执行，该模式可以防御利用简单请求的CSRF攻击。
+	// 严格模式只对Handler和ServeHTTP生效，HandlerFunc无法阻止调用者继续处理请求，因此不会拒绝请求
+	BlockDisallowed bool
+
+	// SafeMethods 是严格模式下不会被拒绝的方法，默认为GET, HEAD, OPTIONS
+	SafeMethods []string
+
+	// AllowSameOrigin 指示严格模式下是否放行同源请求
+	AllowSameOrigin bool
+
+	// RefererFallback 在请求没有Origin头部时从Referer头部推导出请求的源，仅用于严格模式的拒绝判断。
+	// 同源请求的Referer同样会被推导，因此通常需要同时开启AllowSameOrigin
+	RefererFallback bool
+
+	// SkipSameOrigin 跳过同源请求的CORS处理，同源请求指Origin与请求自身的scheme和host相同的请求
+	SkipSameOrigin bool
+
+	// TrustedProxies 是受信任的反向代理的IP或CIDR列表。
+	// 只有来自这些地址的请求才会使用Forwarded或者X-Forwarded-Proto/X-Forwarded-Host头部来确定请求自身的源，
+	// 这些头部包含多个元素时只使用最后一个，即直接相连的反向代理添加的元素
+	TrustedProxies []string
+
+	// ResourceIsolation 开启基于Fetch Metadata的资源隔离策略，为nil时不开启
+	ResourceIsolation *IsolationPolicy
+
+	// CrossOriginResourcePolicy 是响应的Cross-Origin-Resource-Policy头部，可选值为same-origin, same-site, cross-origin
+	CrossOriginResourcePolicy string
+
+	// CrossOriginEmbedderPolicy 是响应的Cross-Origin-Embedder-Policy头部，可选值为require-corp, credentialless, unsafe-none
+	CrossOriginEmbedderPolicy string
+
+	// CrossOriginOpenerPolicy 是响应的Cross-Origin-Opener-Policy头部，可选值为same-origin, same-origin-allow-popups, unsafe-none
+	CrossOriginOpenerPolicy string
+
+	// TimingAllowOrigin 为允许跨域访问的源添加Timing-Allow-Origin头部，使资源计时信息只暴露给这些源
+	TimingAllowOrigin bool
+
+	// AllowPrivateNetwork 允许所有被允许的源发起私有网络访问（Private Network Access）预检请求，
+	// 即携带Access-Control-Request-Private-Network: true的预检请求
+	AllowPrivateNetwork bool
+
+	// PrivateNetworkOrigins 是允许发起私有网络访问的源列表，支持通配符，AllowPrivateNetwork为true时将被忽略
+	PrivateNetworkOrigins []string
+
+	// PreflightStatus 是终止预检请求时响应的状态码。
+	// 为了兼容已有的配置，New默认为200；新的配置应当使用NewChecked，它默认为204，设置了PreflightContentLength时仍为200
+	PreflightStatus int
+
+	// PreflightAllowHeader 在终止预检请求的响应中添加列出允许方法的Allow头部。
+	// 配置了MethodLister时列出路由为请求路径注册的方法，AllowedMethods为"*"并且没有MethodLister时无法列出，不添加Allow头部
+	PreflightAllowHeader bool
+
+	// PreflightContentLength 在终止预检请求的响应中添加Content-Length: 0，只适用于200响应，
+	// 204响应不能包含Content-Length，因此不能与PreflightStatus为204同时使用
+	PreflightContentLength bool
+
+	// PreflightCacheControl 是终止预检请求的响应的Cache-Control头部，为空时不添加
+	PreflightCacheControl string
+
+	// AllowMethodsStyle 决定预检请求的响应如何填写Access-Control-Allow-Methods，默认回显请求的方法
+	AllowMethodsStyle ResponseStyle
+
+	// AllowHeadersStyle 决定预检请求的响应如何填写Access-Control-Allow-Headers，默认回显请求的头部
+	AllowHeadersStyle ResponseStyle
+
+	// MethodLister 从路由获取请求路径上实际注册的方法，设置后只允许路由上注册的方法。
+	// 如果同时设置了AllowedMethods，则方法还必须在AllowedMethods中
+	MethodLister MethodLister
+
+	// AutoExposeHeaders 在响应头部发送之前，将处理器添加的头部自动加入Access-Control-Expose-Headers。
+	// 安全列表响应头部、Access-Control-*和Set-Cookie不会被暴露
+	AutoExposeHeaders bool
+
+	// AutoExposeAllowed 限定可以被自动暴露的头部，支持包含"*"的模式，为空时不限定
+	AutoExposeAllowed []string
+
+	// AutoExposeDenied 是不会被自动暴露的头部，支持包含"*"的模式，优先于AutoExposeAllowed
+	AutoExposeDenied []string
+
+	// LazyHeaders 将实际请求的CORS头部推迟到响应头部发送时（第一次调用WriteHeader、Write或Flush）再添加，
+	// 处理器没有写入任何内容时在处理器返回后添加。处理器在此之前删除或修改这些头部不会产生影响
+	LazyHeaders bool
+
+	// ConflictPolicy 决定LazyHeaders模式下处理器自己设置了Access-Control-*头部时如何处理，默认中间件优先
+	ConflictPolicy ConflictPolicy
+
+	// StaticResponse 开启适合CDN缓存的静态响应模式：总是返回Access-Control-Allow-Origin: *，不添加Vary，
+	// 预检请求的响应列出配置的方法和头部（或者使用"*"），并且默认带有允许共享缓存保存的Cache-Control。
+	// 它要求所有源都被允许并且不允许携带用户凭证，参见Options.Validate。
+	// 配置不兼容时New会忽略StaticResponse，NewChecked则返回错误
+	StaticResponse bool
+
+	// LenientMethods 将所有方法转换为大写后再比较，并且不校验方法是否是合法的token。
+	// 这是旧版本的行为，仅用于兼容
+	LenientMethods bool
+
+	// Learner 开启学习模式，记录所有访问过服务的源
+	Learner *Learner
+
 	// Debug 调试开关
 	Debug bool
 }
@@ -50,68 +181,223 @@ type Cors struct {
 	allowedWOrigins   []wildcard
 	allowOriginFunc   func(origin string) bool
 	allowedHeaders    []string
+	allowedWHeaders   []wildcard
+	deniedHeaders     []string
+	deniedWHeaders    []wildcard
 	allowedMethods    []string
 	exposedHeaders    []string
 	maxAge            int
 	allowedOriginsAll bool
 	allowedHeadersAll bool
+	allowedMethodsAll bool
+	exposedHeadersAll bool
 	allowCredentials  bool
 	optionPassthrough bool
+	reportOnly        bool
+	live              *Cors
+	onViolation       func(v Violation)
+	learner           *Learner
+	rejectMode        RejectMode
+	onReject          func(w http.ResponseWriter, r *http.Request, d Decision)
+	rejectDiagnostics bool
+	blockDisallowed   bool
+	safeMethods       []string
+	allowSameOrigin   bool
+	skipSameOrigin    bool
+	refererFallback   bool
+	trustedProxies    []*net.IPNet
+	isolation         *IsolationPolicy
+	corp              string
+	coep              string
+	coop              string
+	timingAllowOrigin bool
+	privateNetwork    bool
+	pnaOrigins        []string
+	pnaWOrigins       []wildcard
+	pnaOriginsAll     bool
+	methodLister      MethodLister
+	allowMethodsValue string
+	allowHeadersValue string
+	methodsConfigured bool
+	lenientMethods    bool
+
+	methodsUnrestricted bool
+	headersUnrestricted bool
+	varyPreflight       []string
+
+	static             bool
+	lazyHeaders        bool
+	conflictPolicy     ConflictPolicy
+	autoExpose         bool
+	autoExposeAllowed  []string
+	autoExposeWAllowed []wildcard
+	autoExposeDenied   []string
+	autoExposeWDenied  []wildcard
+
+	preflightStatus        int
+	preflightAllow         bool
+	allowHeader            string
+	preflightContentLength bool
+	preflightCacheControl  string
 }
 
-// New 基于给定的options创建一个新的CORS处理器
+// New 基于给定的options创建一个新的CORS处理器。
+// 导致Validate失败的不兼容选项将被忽略并记录在调试日志中，需要得到错误时使用NewChecked
 func New(options Options) *Cors {
+	options, ignored := options.dropIncompatible()
+	c := newCors(options)
+	for _, err := range ignored {
+		c.logf("New: ignoring incompatible option: %v", err)
+	}
+	return c
+}
+
+// NewChecked 基于给定的options创建一个新的CORS处理器，options不能通过Validate检查时返回错误。
+// 它是新配置推荐使用的构造函数，使用新的默认值：终止预检请求的状态码默认为204
+func NewChecked(options Options) (*Cors, error) {
+	if options.PreflightStatus == 0 && !options.PreflightContentLength {
+		options.PreflightStatus = http.StatusNoContent
+	}
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return newCors(options), nil
+}
+
+func newCors(options Options) *Cors {
+	if options.StaticResponse {
+		options.AllowMethodsStyle = ResponseWildcard
+		options.AllowHeadersStyle = ResponseWildcard
+		if options.PreflightCacheControl == "" {
+			options.PreflightCacheControl = staticCacheControl(options.MaxAge)
+		}
+	}
 	c := &Cors{
-		exposedHeaders:    convert(options.ExposedHeaders, http.CanonicalHeaderKey),
 		allowOriginFunc:   options.AllowOriginFunc,
 		allowCredentials:  options.AllowCredentials,
 		maxAge:            options.MaxAge,
 		optionPassthrough: options.OptionsPassthrough,
+		reportOnly:        options.ReportOnly,
+		live:              options.LivePolicy,
+		onViolation:       options.OnViolation,
+		learner:           options.Learner,
+		rejectMode:        options.RejectMode,
+		onReject:          options.OnReject,
+		rejectDiagnostics: options.RejectDiagnostics,
+		blockDisallowed:   options.BlockDisallowed,
+		allowSameOrigin:   options.AllowSameOrigin,
+		skipSameOrigin:    options.SkipSameOrigin,
+		refererFallback:   options.RefererFallback,
+		isolation:         options.ResourceIsolation,
+		corp:              options.CrossOriginResourcePolicy,
+		coep:              options.CrossOriginEmbedderPolicy,
+		coop:              options.CrossOriginOpenerPolicy,
+		timingAllowOrigin: options.TimingAllowOrigin,
+		methodLister:      options.MethodLister,
+		methodsConfigured: len(options.AllowedMethods) > 0,
+		lenientMethods:    options.LenientMethods,
+		autoExpose:        options.AutoExposeHeaders,
+		static:            options.StaticResponse,
+		lazyHeaders:       options.LazyHeaders,
+		conflictPolicy:    options.ConflictPolicy,
+
+		preflightStatus:        options.PreflightStatus,
+		preflightAllow:         options.PreflightAllowHeader,
+		preflightContentLength: options.PreflightContentLength,
+		preflightCacheControl:  options.PreflightCacheControl,
 	}
 	if options.Debug {
 		c.log = log.New(os.Stdout, "[cors] ", log.LstdFlags)
 	}
+	if c.reportOnly && c.live == nil {
+		c.live = permissivePolicy(options)
+	}
+	c.trustedProxies = c.parseTrustedProxies(options.TrustedProxies)
+
+	if options.AllowPrivateNetwork {
+		c.privateNetwork = true
+		c.pnaOriginsAll = true
+	} else if len(options.PrivateNetworkOrigins) > 0 {
+		c.privateNetwork = true
+		c.pnaOrigins, c.pnaWOrigins, c.pnaOriginsAll = parseOrigins(options.PrivateNetworkOrigins)
+	}
 
 	if len(options.AllowedOrigins) == 0 {
 		if options.AllowOriginFunc == nil {
 			c.allowedOriginsAll = true
 		}
 	} else {
-		c.allowedOrigins = []string{}
-		c.allowedWOrigins = []wildcard{}
-		for _, origin := range options.AllowedOrigins {
-			origin = strings.ToLower(origin)
-			if origin == "*" {
-				c.allowedOriginsAll = true
-				c.allowedOrigins = nil
-				c.allowedWOrigins = nil
-				break
-			} else if i := strings.IndexByte(origin, '*'); i >= 0 {
-				w := wildcard{origin[:i], origin[i+1:]}
-				c.allowedWOrigins = append(c.allowedWOrigins, w)
-			} else {
-				c.allowedOrigins = append(c.allowedOrigins, origin)
-			}
-		}
+		c.allowedOrigins, c.allowedWOrigins, c.allowedOriginsAll = parseOrigins(options.AllowedOrigins)
 	}
 
 	if len(options.AllowedHeaders) == 0 {
 		c.allowedHeaders = []string{"Origin", "Accept", "Content-Type", "X-Requested-With"}
 	} else {
-		c.allowedHeaders = convert(append(options.AllowedHeaders, "Origin"), http.CanonicalHeaderKey)
-		for _, h := range options.AllowedHeaders {
-			if h == "*" {
-				c.allowedHeadersAll = true
-				c.allowedHeaders = nil
-				break
-			}
+		c.allowedHeaders, c.allowedWHeaders, c.allowedHeadersAll = parseHeaderPatterns(append([]string{"Origin"}, options.AllowedHeaders...))
+	}
+	c.deniedHeaders, c.deniedWHeaders, _ = parseHeaderPatterns(options.DeniedHeaders)
+	if options.AutoExposeHeaders {
+		var all bool
+		c.autoExposeAllowed, c.autoExposeWAllowed, all = parseHeaderPatterns(options.AutoExposeAllowed)
+		if all {
+			c.autoExposeAllowed, c.autoExposeWAllowed = nil, nil
 		}
+		c.autoExposeDenied, c.autoExposeWDenied, _ = parseHeaderPatterns(options.AutoExposeDenied)
 	}
 
 	if len(options.AllowedMethods) == 0 {
 		c.allowedMethods = []string{"GET", "POST", "HEAD"}
 	} else {
-		c.allowedMethods = convert(options.AllowedMethods, strings.ToUpper)
+		c.allowedMethods = []string{}
+		for _, m := range options.AllowedMethods {
+			switch {
+			case m == "*":
+				c.allowedMethodsAll = true
+			case !c.lenientMethods && !isToken(m):
+				c.logf("New: ignoring invalid method '%s'", m)
+			default:
+				m = c.normalizeMethod(m)
+				if m != strings.ToUpper(m) {
+					// 旧版本会将方法转换为大写，现在浏览器发送的大写方法不会匹配
+					c.logf("New: method '%s' is case-sensitive and will not match '%s', use LenientMethods for the previous behavior",
+						m, strings.ToUpper(m))
+				}
+				c.allowedMethods = append(c.allowedMethods, m)
+			}
+		}
+	}
+
+	c.exposedHeaders = []string{}
+	for _, h := range options.ExposedHeaders {
+		if h == "*" {
+			c.exposedHeadersAll = true
+		} else {
+			c.exposedHeaders = append(c.exposedHeaders, http.CanonicalHeaderKey(h))
+		}
+	}
+	if c.exposedHeadersAll && c.allowCredentials {
+		// 携带凭证时"*"只表示名为"*"的头部，因此只暴露明确列出的头部
+		c.logf("New: ExposedHeaders '*' is ignored when credentials are allowed")
+	}
+
+	c.allowMethodsValue = c.allowMethodsResponse(options.AllowMethodsStyle)
+	c.allowHeadersValue = c.allowHeadersResponse(options.AllowHeadersStyle)
+	// 响应固定为"*"时请求的方法或头部不会影响响应
+	c.methodsUnrestricted = c.allowMethodsValue == "*" && c.methodLister == nil
+	c.headersUnrestricted = c.allowHeadersValue == "*, Authorization"
+	c.varyPreflight = c.preflightVary()
+
+	if c.preflightStatus == 0 {
+		c.preflightStatus = http.StatusOK
+	}
+	if !c.allowedMethodsAll {
+		c.allowHeader = allowValue(c.allowedMethods)
+	}
+
+	if len(options.SafeMethods) == 0 {
+		c.safeMethods = []string{"GET", "HEAD", "OPTIONS"}
+	} else {
+		c.safeMethods = convert(options.SafeMethods, c.normalizeMethod)
 	}
 
 	return c
@@ -127,150 +413,269 @@ func AllowAll() *Cors {
 	return New(Options{
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"HEAD", "GET", "POST", "PUT", "PATCH", "DELETE"},
-		AllowedHeaders:   []string{"*"},
+		AllowedHeaders:   []string{"*", "Authorization"},
 		AllowCredentials: true,
 		MaxAge:           86400,
 	})
 }
 
+// permissivePolicy 返回仅报告模式下没有设置LivePolicy时生效的宽松策略，
+// 它允许所有源、方法和头部，但不允许携带用户凭证
+func permissivePolicy(options Options) *Cors {
+	return New(Options{
+		AllowedMethods:     []string{"*"},
+		AllowedHeaders:     []string{"*"},
+		ExposedHeaders:     options.ExposedHeaders,
+		MaxAge:             options.MaxAge,
+		OptionsPassthrough: options.OptionsPassthrough,
+		Debug:              options.Debug,
+	})
+}
+
 // Handler 为请求应用指定的CORS规范
 func (c *Cors) Handler(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-			c.logf("Handler: Preflight request")
-			c.handlePreflight(w, r)
-
-			if c.optionPassthrough {
-				h.ServeHTTP(w, r)
-			} else {
-				w.WriteHeader(http.StatusOK)
-			}
-		} else {
-			c.logf("Handler: Actual request")
-			c.handleActualRequest(w, r)
-			h.ServeHTTP(w, r)
-		}
+		c.handle(w, r, h, "Handler")
 	})
 }
 
-// HandlerFunc 提供兼容的处理器函数
+// HandlerFunc 提供兼容的处理器函数。
+// 它无法阻止调用者继续处理请求，因此BlockDisallowed对它不生效
 func (c *Cors) HandlerFunc(w http.ResponseWriter, r *http.Request) {
-	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-		c.logf("HandlerFunc: Preflight request")
-		c.handlePreflight(w, r)
-	} else {
-		c.logf("HandlerFunc: Actual request")
-		c.handleActualRequest(w, r)
-	}
+	c.handle(w, r, nil, "HandlerFunc")
 }
 
 // ServeHTTP 提供兼容性接口
 func (c *Cors) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
-		c.logf("ServeHTTP: Preflight request")
-		c.handlePreflight(w, r)
+	c.handle(w, r, next, "ServeHTTP")
+}
+
+// handle 为请求应用CORS规范，next为nil时不调用后续的处理程序。
+// 返回实际生效的判定结果，请求在评估CORS策略之前就被处理（同源请求、资源隔离、静态响应）时返回false
+func (c *Cors) handle(w http.ResponseWriter, r *http.Request, next http.Handler, caller string) (Decision, bool) {
+	if c.reportOnly {
+		c.reportViolations(r)
+		return c.live.handle(w, r, next, caller)
+	}
+	if c.skipSameOriginRequest(r) {
+		c.logf("%s: Same-origin request", caller)
+		// 同源请求的响应不包含CORS头部，需要与跨域请求的响应区分缓存
+		addVary(w.Header(), "Origin")
+		c.addCompanionHeaders(w)
+		if next != nil {
+			next.ServeHTTP(w, r)
+		}
+		return Decision{}, false
+	}
+	if c.handleIsolation(w, r) {
+		return Decision{}, false
+	}
+	if c.static {
+		c.logf("%s: Static response", caller)
+		c.handleStatic(w, r, next)
+		return Decision{}, false
+	}
+
+	if isPreflight(r) {
+		c.logf("%s: Preflight request", caller)
+		d, rejected := c.handlePreflight(w, r)
+		if rejected {
+			return d, true
+		}
 
 		if c.optionPassthrough {
-			next(w, r)
+			if next != nil {
+				next.ServeHTTP(w, withPreflight(r, c, d))
+			}
 		} else {
-			w.WriteHeader(http.StatusOK)
+			c.terminatePreflight(w, d)
 		}
-	} else {
-		c.logf("ServeHTTP: Actual request")
-		c.handleActualRequest(w, r)
-		next(w, r)
+		return d, true
+	}
+
+	c.logf("%s: Actual request", caller)
+	if c.lazyHeaders {
+		return c.serveLazy(w, r, next), true
+	}
+	d, blocked := c.handleActualRequest(w, r, next != nil)
+	if !blocked && next != nil {
+		c.serveActual(w, r, next)
 	}
+	return d, true
 }
 
-func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) {
+// terminatePreflight 结束预检请求的响应
+func (c *Cors) terminatePreflight(w http.ResponseWriter, d Decision) {
 	headers := w.Header()
-	origin := r.Header.Get("Origin")
-
-	if r.Method != http.MethodOptions {
-		c.logf("    Preflight aborted: %s!=OPTIONS", r.Method)
-		return
+	if c.preflightAllow {
+		allow := c.allowHeader
+		if c.methodLister != nil {
+			allow = allowValue(d.RouteMethods)
+		}
+		if allow != "" {
+			headers.Set("Allow", allow)
+		}
 	}
-
-	headers.Add("Vary", "Origin")
-	headers.Add("Vary", "Access-Control-Request-Method")
-	headers.Add("Vary", "Access-Control-Request-Headers")
-
-	if origin == "" {
-		c.logf("    Preflight aborted: empty origin")
-		return
+	if c.preflightContentLength {
+		headers.Set("Content-Length", "0")
 	}
-	if !c.isOriginAllowed(origin) {
-		c.logf("    Preflight aborted: origin '%s' not allowed", origin)
-		return
+	if c.preflightCacheControl != "" {
+		headers.Set("Cache-Control", c.preflightCacheControl)
 	}
+	w.WriteHeader(c.preflightStatus)
+}
 
-	reqMethod := r.Header.Get("Access-Control-Request-Method")
-	if !c.isMethodAllowed(reqMethod) {
-		c.logf("    Preflight aborted: method '%s' not allowed", reqMethod)
-		return
+// allowValue 返回Allow头部的值，OPTIONS总是被允许
+func allowValue(methods []string) string {
+	for _, m := range methods {
+		if m == http.MethodOptions {
+			return strings.Join(methods, ", ")
+		}
 	}
+	return strings.Join(append(methods[:len(methods):len(methods)], http.MethodOptions), ", ")
+}
 
-	reqHeaders := parseHeaderList(r.Header.Get("Access-Control-Request-Headers"))
-	if !c.areHeadersAllowed(reqHeaders) {
-		c.logf("Preflight aborted: headers '%v' not allowed", reqHeaders)
-		return
+// handlePreflight 为预检请求添加CORS头部并返回判定结果，如果请求被拒绝并且已经写入了拒绝响应则返回true
+func (c *Cors) handlePreflight(w http.ResponseWriter, r *http.Request) (Decision, bool) {
+	headers := w.Header()
+	d := c.evaluatePreflight(r)
+	if c.learner != nil {
+		c.learner.record(d)
+	}
+
+	addVary(headers, c.varyPreflight...)
+
+	if !d.Allowed {
+		switch d.Reason {
+		case ReasonMissingOrigin:
+			c.logf("    Preflight aborted: empty origin")
+		case ReasonOriginNotAllowed:
+			c.logf("    Preflight aborted: origin '%s' not allowed", d.Origin)
+		case ReasonInvalidHeaders:
+			c.logf("    Preflight aborted: malformed Access-Control-Request-Headers")
+		case ReasonInvalidMethod:
+			c.logf("    Preflight aborted: malformed method '%s'", d.Method)
+		case ReasonMethodNotAllowed:
+			c.logf("    Preflight aborted: method '%s' not allowed", d.Method)
+		case ReasonHeadersNotAllowed:
+			c.logf("    Preflight aborted: headers '%v' not allowed", d.Headers)
+		case ReasonPrivateNetworkNotAllowed:
+			c.logf("    Preflight aborted: private network access from '%s' not allowed", d.Origin)
+		}
+		c.report(r, d)
+		return d, c.reject(w, r, d)
 	}
 
 	if c.allowedOriginsAll && !c.allowCredentials {
 		headers.Set("Access-Control-Allow-Origin", "*")
 	} else {
-		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Set("Access-Control-Allow-Origin", d.Origin)
 	}
 
-	headers.Set("Access-Control-Allow-Methods", strings.ToUpper(reqMethod))
-	if len(reqHeaders) > 0 {
-		headers.Set("Access-Control-Allow-Headers", strings.Join(reqHeaders, ", "))
+	if d.RouteMethods != nil {
+		headers.Set("Access-Control-Allow-Methods", strings.Join(d.RouteMethods, ", "))
+	} else if c.allowMethodsValue != "" {
+		headers.Set("Access-Control-Allow-Methods", c.allowMethodsValue)
+	} else {
+		headers.Set("Access-Control-Allow-Methods", c.normalizeMethod(d.Method))
+	}
+	if c.allowHeadersValue != "" {
+		headers.Set("Access-Control-Allow-Headers", c.allowHeadersValue)
+	} else if len(d.Headers) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(d.Headers, ", "))
 	}
 	if c.allowCredentials {
 		headers.Set("Access-Control-Allow-Credentials", "true")
 	}
+	if c.privateNetwork && d.PrivateNetwork {
+		headers.Set("Access-Control-Allow-Private-Network", "true")
+	}
 	if c.maxAge > 0 {
 		headers.Set("Access-Control-Max-Age", strconv.Itoa(c.maxAge))
 	}
 	c.logf("    Preflight response headers: %v", headers)
+	return d, false
 }
 
-func (c *Cors) handleActualRequest(w http.ResponseWriter, r *http.Request) {
+// handleActualRequest 为实际请求添加CORS头部并返回判定结果，如果请求在严格模式下被拒绝则返回true。
+// canBlock为false时没有可以阻止的后续处理程序，严格模式不生效
+func (c *Cors) handleActualRequest(w http.ResponseWriter, r *http.Request, canBlock bool) (Decision, bool) {
 	headers := w.Header()
-	origin := r.Header.Get("Origin")
+	d := c.evaluateActualRequest(r)
+	if c.learner != nil && !d.OriginDerived {
+		c.learner.record(d)
+	}
+	c.addCompanionHeaders(w)
 
-	if r.Method == http.MethodOptions {
+	if d.Reason == ReasonOptionsRequest {
 		c.logf("    Actual request no headers added: method == %s", r.Method)
-		return
+		return d, false
+	}
+
+	addVary(headers, "Origin")
+	if !d.Allowed {
+		switch d.Reason {
+		case ReasonMissingOrigin:
+			c.logf("    Actual request no headers added: missing origin")
+		case ReasonOriginNotAllowed:
+			c.logf("    Actual request no headers added: origin '%s' not allowed", d.Origin)
+		case ReasonInvalidMethod:
+			c.logf("    Actual request no headers added: malformed method '%s'", d.Method)
+		case ReasonMethodNotAllowed:
+			c.logf("    Actual request no headers added: method '%s' not allowed", d.Method)
+		}
+		if c.shouldBlock(r, d) {
+			if canBlock {
+				c.logf("    Actual request blocked: method '%s' from origin '%s'", d.Method, d.Origin)
+				c.notify(r, d)
+				c.writeReject(w, r, d)
+				return d, true
+			}
+			c.logf("    Actual request not blocked: BlockDisallowed requires Handler or ServeHTTP")
+		}
+		d = d.withoutDerivedOrigin()
+		c.report(r, d)
+		return d, false
 	}
 
-	headers.Add("Vary", "Origin")
-	if origin == "" {
-		c.logf("    Actual request no headers added: missing origin")
-		return
-	}
-	if !c.isOriginAllowed(origin) {
-		c.logf("    Actual request no headers added: origin '%s' not allowed", origin)
-		return
+	if d.OriginDerived {
+		c.logf("    Actual request no headers added: origin '%s' derived from referer", d.Origin)
+		return d.withoutDerivedOrigin(), false
 	}
 
-	if !c.isMethodAllowed(r.Method) {
-		c.logf("    Actual request no headers added: method '%s' not allowed", r.Method)
-		return
-	}
 	if c.allowedOriginsAll && !c.allowCredentials {
 		headers.Set("Access-Control-Allow-Origin", "*")
 	} else {
-		headers.Set("Access-Control-Allow-Origin", origin)
+		headers.Set("Access-Control-Allow-Origin", d.Origin)
 	}
 
-	if len(c.exposedHeaders) > 0 {
+	if c.exposedHeadersAll && !c.allowCredentials {
+		headers.Set("Access-Control-Expose-Headers", "*")
+	} else if len(c.exposedHeaders) > 0 {
 		headers.Set("Access-Control-Expose-Headers", strings.Join(c.exposedHeaders, ", "))
 	}
 	if c.allowCredentials {
 		headers.Set("Access-Control-Allow-Credentials", "true")
 	}
+	if c.timingAllowOrigin && c.isOriginAllowed(d.Origin) {
+		headers.Set("Timing-Allow-Origin", headers.Get("Access-Control-Allow-Origin"))
+	}
 	c.logf("    Actual response added headers: %v", headers)
+	return d, false
+}
+
+// addCompanionHeaders 添加与CORS配合使用的跨域隔离头部
+func (c *Cors) addCompanionHeaders(w http.ResponseWriter) {
+	headers := w.Header()
+	if c.corp != "" {
+		headers.Set("Cross-Origin-Resource-Policy", c.corp)
+	}
+	if c.coep != "" {
+		headers.Set("Cross-Origin-Embedder-Policy", c.coep)
+	}
+	if c.coop != "" {
+		headers.Set("Cross-Origin-Opener-Policy", c.coop)
+	}
 }
 
 func (c *Cors) logf(format string, a ...interface{}) {
@@ -286,29 +691,30 @@ func (c *Cors) isOriginAllowed(origin string) bool {
 	if c.allowedOriginsAll {
 		return true
 	}
-	origin = strings.ToLower(origin)
-	for _, o := range c.allowedOrigins {
-		if o == origin {
-			return true
-		}
-	}
-	for _, w := range c.allowedWOrigins {
-		if w.match(origin) {
-			return true
-		}
+	return matchOrigin(origin, c.allowedOrigins, c.allowedWOrigins)
+}
+
+func (c *Cors) isPrivateNetworkAllowed(origin string) bool {
+	if !c.privateNetwork {
+		return false
 	}
-	return false
+	return c.pnaOriginsAll || matchOrigin(origin, c.pnaOrigins, c.pnaWOrigins)
 }
 
 func (c *Cors) isMethodAllowed(method string) bool {
-	if len(c.allowedMethods) == 0 {
+	return c.allowedMethodsAll || c.methodAllowedIn(method, c.allowedMethods)
+}
+
+// methodAllowedIn 判断方法是否在allowed中，allowed中的方法必须已经规范化
+func (c *Cors) methodAllowedIn(method string, allowed []string) bool {
+	if len(allowed) == 0 {
 		return false
 	}
-	method = strings.ToUpper(method)
+	method = c.normalizeMethod(method)
 	if method == http.MethodOptions {
 		return true
 	}
-	for _, m := range c.allowedMethods {
+	for _, m := range allowed {
 		if m == method {
 			return true
 		}
@@ -317,18 +723,17 @@ func (c *Cors) isMethodAllowed(method string) bool {
 }
 
 func (c *Cors) areHeadersAllowed(reqHeaders []string) bool {
-	if c.allowedHeadersAll || len(reqHeaders) == 0 {
-		return true
-	}
 	for _, header := range reqHeaders {
 		header = http.CanonicalHeaderKey(header)
-		found := false
-		for _, h := range c.allowedHeaders {
-			if h == header {
-				found = true
-			}
+		if matchHeader(header, c.deniedHeaders, c.deniedWHeaders) {
+			return false
+		}
+		// 预检请求只列出名称，值未知时仍然安全的头部（如Accept）总是允许的
+		if IsSafelistedHeader(header, "") || matchHeader(header, c.allowedHeaders, c.allowedWHeaders) {
+			continue
 		}
-		if !found {
+		// 按照Fetch规范，通配符"*"不包括Authorization头部，它必须被明确列出
+		if !c.allowedHeadersAll || header == "Authorization" {
 			return false
 		}
 	}
@@ -22,6 +22,7 @@ var allHeaders = []string{
 	"Access-Control-Allow-Credentials",
 	"Access-Control-Max-Age",
 	"Access-Control-Expose-Headers",
+	"Access-Control-Allow-Private-Network",
 }
 
 func assertHeaders(t *testing.T, resHeaders http.Header, expHeaders map[string]string) {
@@ -274,6 +275,199 @@ func TestSpec(t *testing.T) {
 				"Access-Control-Allow-Headers": "X-Header-2, X-Header-1",
 			},
 		},
+		{
+			"AllowedHeaderPattern",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"X-Amz-*", "X-Tenant-*"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "x-amz-date, X-TENANT-ID",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "X-Amz-Date, X-Tenant-Id",
+			},
+		},
+		{
+			"DisallowedHeaderPattern",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"X-Amz-*"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Amz-Date, X-Amzdate",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"DeniedHeaderOverridesWildcard",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"*"},
+				DeniedHeaders:  []string{"X-Internal-*", "x-debug"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-1, X-Internal-Auth",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"DeniedHeaderExact",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"X-Debug", "X-Header-1"},
+				DeniedHeaders:  []string{"x-debug"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Debug",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"WildcardHeaderExcludesAuthorization",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"*"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-1, Authorization",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"WildcardHeaderExcludesAuthorizationWithCredentials",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowedHeaders:   []string{"*"},
+				AllowCredentials: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "Authorization",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"WildcardHeaderExplicitAuthorization",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedHeaders: []string{"*", "Authorization"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-1, Authorization",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+				"Access-Control-Allow-Headers": "X-Header-1, Authorization",
+			},
+		},
+		{
+			"WildcardHeaderExplicitAuthorizationWithCredentials",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowedHeaders:   []string{"*", "Authorization"},
+				AllowCredentials: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                         "http://foobar.com",
+				"Access-Control-Request-Method":  "GET",
+				"Access-Control-Request-Headers": "X-Header-1, Authorization",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "http://foobar.com",
+				"Access-Control-Allow-Methods":     "GET",
+				"Access-Control-Allow-Headers":     "X-Header-1, Authorization",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"WildcardMethod",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedMethods: []string{"*"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "PATCH",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "PATCH",
+			},
+		},
+		{
+			"WildcardMethodWithCredentials",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowedMethods:   []string{"*"},
+				AllowCredentials: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "PATCH",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":      "http://foobar.com",
+				"Access-Control-Allow-Methods":     "PATCH",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
+		{
+			"WildcardMethodActualRequest",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				AllowedMethods: []string{"*"},
+			},
+			"DELETE",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+				"Access-Control-Allow-Origin": "http://foobar.com",
+			},
+		},
 		{
 			"DisallowedHeader",
 			Options{
@@ -324,6 +518,40 @@ func TestSpec(t *testing.T) {
 				"Access-Control-Expose-Headers": "X-Header-1, X-Header-2",
 			},
 		},
+		{
+			"WildcardExposedHeaders",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ExposedHeaders: []string{"*", "X-Header-1"},
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+				"Access-Control-Allow-Origin":   "http://foobar.com",
+				"Access-Control-Expose-Headers": "*",
+			},
+		},
+		{
+			"WildcardExposedHeadersWithCredentials",
+			Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				ExposedHeaders:   []string{"*", "X-Header-1"},
+				AllowCredentials: true,
+			},
+			"GET",
+			map[string]string{
+				"Origin": "http://foobar.com",
+			},
+			map[string]string{
+				"Vary": "Origin",
+				"Access-Control-Allow-Origin":      "http://foobar.com",
+				"Access-Control-Expose-Headers":    "X-Header-1",
+				"Access-Control-Allow-Credentials": "true",
+			},
+		},
 		{
 			"AllowedCredentials",
 			Options{
@@ -358,6 +586,94 @@ func TestSpec(t *testing.T) {
 				"Access-Control-Allow-Methods": "GET",
 			},
 		},
+		{
+			"AllowedPrivateNetwork",
+			Options{
+				AllowedOrigins:      []string{"http://foobar.com"},
+				AllowPrivateNetwork: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                                "http://foobar.com",
+				"Access-Control-Request-Method":         "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+				"Access-Control-Allow-Origin":          "http://foobar.com",
+				"Access-Control-Allow-Methods":         "GET",
+				"Access-Control-Allow-Private-Network": "true",
+			},
+		},
+		{
+			"AllowedPrivateNetworkOrigin",
+			Options{
+				AllowedOrigins:        []string{"http://foobar.com", "http://barbaz.com"},
+				PrivateNetworkOrigins: []string{"http://*.foobar.com", "http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                                "http://foobar.com",
+				"Access-Control-Request-Method":         "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+				"Access-Control-Allow-Origin":          "http://foobar.com",
+				"Access-Control-Allow-Methods":         "GET",
+				"Access-Control-Allow-Private-Network": "true",
+			},
+		},
+		{
+			"DisallowedPrivateNetworkOrigin",
+			Options{
+				AllowedOrigins:        []string{"http://foobar.com", "http://barbaz.com"},
+				PrivateNetworkOrigins: []string{"http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                                "http://barbaz.com",
+				"Access-Control-Request-Method":         "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+			},
+		},
+		{
+			"PrivateNetworkNotConfigured",
+			Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                                "http://foobar.com",
+				"Access-Control-Request-Method":         "GET",
+				"Access-Control-Request-Private-Network": "true",
+			},
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
+		{
+			"PrivateNetworkNotRequested",
+			Options{
+				AllowedOrigins:      []string{"http://foobar.com"},
+				AllowPrivateNetwork: true,
+			},
+			"OPTIONS",
+			map[string]string{
+				"Origin":                        "http://foobar.com",
+				"Access-Control-Request-Method": "GET",
+			},
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers, Access-Control-Request-Private-Network",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET",
+			},
+		},
 		{
 			"NonPreflightOptions",
 			Options{
@@ -408,3 +724,161 @@ func TestIsMethodAllowedReturnsTrueWithOptions(t *testing.T) {
 	s := New(Options{})
 	assert.True(t, s.isMethodAllowed(http.MethodOptions))
 }
+
+func TestCompanionHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:            []string{"http://foobar.com"},
+		CrossOriginResourcePolicy: "same-site",
+		CrossOriginEmbedderPolicy: "require-corp",
+		CrossOriginOpenerPolicy:   "same-origin",
+		TimingAllowOrigin:         true,
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Cross-Origin-Resource-Policy"), "same-site")
+	assert.DeepEqual(t, res.Header().Get("Cross-Origin-Embedder-Policy"), "require-corp")
+	assert.DeepEqual(t, res.Header().Get("Cross-Origin-Opener-Policy"), "same-origin")
+	assert.DeepEqual(t, res.Header().Get("Timing-Allow-Origin"), "http://foobar.com")
+
+	req.Header.Set("Origin", "http://barbaz.com")
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Cross-Origin-Resource-Policy"), "same-site")
+	assert.DeepEqual(t, res.Header().Get("Timing-Allow-Origin"), "")
+}
+
+func TestTimingAllowOriginAll(t *testing.T) {
+	s := New(Options{TimingAllowOrigin: true})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Timing-Allow-Origin"), "*")
+	assert.DeepEqual(t, res.Header().Get("Cross-Origin-Resource-Policy"), "")
+}
+
+func TestPreflightTermination(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		status  int
+		headers map[string]string
+	}{
+		{
+			"Default",
+			Options{},
+			http.StatusOK,
+			map[string]string{"Allow": "", "Content-Length": "", "Cache-Control": ""},
+		},
+		{
+			"Configured",
+			Options{
+				AllowedMethods:         []string{"GET", "PUT"},
+				PreflightAllowHeader:   true,
+				PreflightContentLength: true,
+				PreflightCacheControl:  "max-age=600",
+			},
+			http.StatusOK,
+			map[string]string{"Allow": "GET, PUT, OPTIONS", "Content-Length": "0", "Cache-Control": "max-age=600"},
+		},
+		{
+			"NoContent",
+			Options{
+				PreflightStatus:      http.StatusNoContent,
+				PreflightAllowHeader: true,
+			},
+			http.StatusNoContent,
+			map[string]string{"Allow": "GET, POST, HEAD, OPTIONS", "Content-Length": ""},
+		},
+		{
+			"WildcardMethods",
+			Options{
+				AllowedMethods:       []string{"*"},
+				PreflightAllowHeader: true,
+			},
+			http.StatusOK,
+			map[string]string{"Allow": ""},
+		},
+		{
+			"MethodLister",
+			Options{
+				AllowedMethods:       []string{"*"},
+				PreflightAllowHeader: true,
+				MethodLister: MethodListerFunc(func(r *http.Request) []string {
+					return []string{"GET", "DELETE"}
+				}),
+			},
+			http.StatusOK,
+			map[string]string{"Allow": "GET, DELETE, OPTIONS"},
+		},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		s := New(tc.options)
+		for name, serve := range map[string]func(w http.ResponseWriter, r *http.Request){
+			"Handler":     s.Handler(testHandler).ServeHTTP,
+			"HandlerFunc": s.HandlerFunc,
+			"ServeHTTP": func(w http.ResponseWriter, r *http.Request) {
+				s.ServeHTTP(w, r, testHandler)
+			},
+		} {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+				req.Header.Set("Origin", "http://foobar.com")
+				req.Header.Set("Access-Control-Request-Method", "GET")
+
+				res := httptest.NewRecorder()
+				serve(res, req)
+				assert.DeepEqual(t, res.Code, tc.status)
+				assert.DeepEqual(t, res.Body.String(), "")
+				for name, value := range tc.headers {
+					assert.DeepEqual(t, res.Header().Get(name), value)
+				}
+			})
+		}
+	}
+}
+
+func TestNewCheckedPreflightStatus(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		status  int
+	}{
+		{"Default", Options{}, http.StatusNoContent},
+		{"ContentLength", Options{PreflightContentLength: true}, http.StatusOK},
+		{"Explicit", Options{PreflightStatus: http.StatusOK}, http.StatusOK},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := NewChecked(tc.options)
+			assert.Nil(t, err)
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, newPreflight("http://foobar.com", "GET"))
+			assert.DeepEqual(t, res.Code, tc.status)
+		})
+	}
+}
+
+func TestPreflightPassthroughNotTerminated(t *testing.T) {
+	s := New(Options{OptionsPassthrough: true})
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+	assert.DeepEqual(t, res.Body.String(), "hello")
+}
+
+func TestAllowAllAllowsAuthorization(t *testing.T) {
+	s := AllowAll()
+	assert.True(t, s.areHeadersAllowed([]string{"Authorization", "X-Header-1"}))
+}
@@ -0,0 +1,247 @@
+package cors
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+// Policy 是编译后的CORS策略的快照
+type Policy struct {
+	AllowedOrigins         []string `json:"allowed_origins"`
+	AllowedWildcardOrigins []string `json:"allowed_wildcard_origins"`
+	AllowedOriginsAll      bool     `json:"allowed_origins_all"`
+	AllowOriginFunc        bool     `json:"allow_origin_func"`
+	AllowedMethods         []string `json:"allowed_methods"`
+	AllowedMethodsAll      bool     `json:"allowed_methods_all"`
+	AllowedHeaders         []string `json:"allowed_headers"`
+	AllowedHeaderPatterns  []string `json:"allowed_header_patterns"`
+	AllowedHeadersAll      bool     `json:"allowed_headers_all"`
+	DeniedHeaders          []string `json:"denied_headers"`
+	ExposedHeaders         []string `json:"exposed_headers"`
+	ExposedHeadersAll      bool     `json:"exposed_headers_all"`
+	AllowCredentials       bool     `json:"allow_credentials"`
+	MaxAge                 int      `json:"max_age"`
+	OptionsPassthrough     bool     `json:"options_passthrough"`
+	ReportOnly             bool     `json:"report_only"`
+	StaticResponse         bool     `json:"static_response"`
+	MethodLister           bool     `json:"method_lister"`
+	LenientMethods         bool     `json:"lenient_methods"`
+	BlockDisallowed        bool     `json:"block_disallowed"`
+	RejectMode             string   `json:"reject_mode"`
+	PrivateNetwork         bool     `json:"private_network"`
+	PrivateNetworkOrigins  []string `json:"private_network_origins"`
+	// AllowMethodsResponse和AllowHeadersResponse是预检请求响应中的固定值，为"echo"时回显请求的值
+	AllowMethodsResponse string `json:"allow_methods_response"`
+	AllowHeadersResponse string `json:"allow_headers_response"`
+}
+
+// Policy 返回当前生效的策略
+func (c *Cors) Policy() Policy {
+	p := Policy{
+		AllowedOrigins:         append([]string{}, c.allowedOrigins...),
+		AllowedWildcardOrigins: []string{},
+		AllowedOriginsAll:      c.allowedOriginsAll,
+		AllowOriginFunc:        c.allowOriginFunc != nil,
+		AllowedMethods:         append([]string{}, c.allowedMethods...),
+		AllowedMethodsAll:      c.allowedMethodsAll,
+		AllowedHeaders:         append([]string{}, c.allowedHeaders...),
+		AllowedHeaderPatterns:  []string{},
+		AllowedHeadersAll:      c.allowedHeadersAll,
+		DeniedHeaders:          append([]string{}, c.deniedHeaders...),
+		ExposedHeaders:         append([]string{}, c.exposedHeaders...),
+		ExposedHeadersAll:      c.exposedHeadersAll,
+		AllowCredentials:       c.allowCredentials,
+		MaxAge:                 c.maxAge,
+		OptionsPassthrough:     c.optionPassthrough,
+		ReportOnly:             c.reportOnly,
+		StaticResponse:         c.static,
+		MethodLister:           c.methodLister != nil,
+		LenientMethods:         c.lenientMethods,
+		BlockDisallowed:        c.blockDisallowed,
+		RejectMode:             c.rejectModeName(),
+		PrivateNetwork:         c.privateNetwork,
+		PrivateNetworkOrigins:  append([]string{}, c.pnaOrigins...),
+		AllowMethodsResponse:   c.allowMethodsValue,
+		AllowHeadersResponse:   c.allowHeadersValue,
+	}
+	if c.pnaOriginsAll {
+		p.PrivateNetworkOrigins = append(p.PrivateNetworkOrigins, "*")
+	}
+	for _, w := range c.pnaWOrigins {
+		p.PrivateNetworkOrigins = append(p.PrivateNetworkOrigins, w.prefix+"*"+w.suffix)
+	}
+	if p.AllowMethodsResponse == "" {
+		p.AllowMethodsResponse = "echo"
+	}
+	if p.AllowHeadersResponse == "" {
+		p.AllowHeadersResponse = "echo"
+	}
+	for _, w := range c.allowedWOrigins {
+		p.AllowedWildcardOrigins = append(p.AllowedWildcardOrigins, w.prefix+"*"+w.suffix)
+	}
+	for _, w := range c.allowedWHeaders {
+		p.AllowedHeaderPatterns = append(p.AllowedHeaderPatterns, w.prefix+"*"+w.suffix)
+	}
+	for _, w := range c.deniedWHeaders {
+		p.DeniedHeaders = append(p.DeniedHeaders, w.prefix+"*"+w.suffix)
+	}
+	return p
+}
+
+// rejectModeName 返回拒绝响应方式的名称
+func (c *Cors) rejectModeName() string {
+	switch {
+	case c.onReject != nil:
+		return "custom"
+	case c.rejectMode == RejectForbidden:
+		return "forbidden"
+	}
+	return "passthrough"
+}
+
+// Simulate 模拟一个来自origin、访问target的请求并返回策略的判定结果。
+// target是请求的路径或者完整的URL，为空时使用"/"，配置了MethodLister时使用它对应的路由。
+// preflight为true时模拟请求方法为method、请求头部为headers的预检请求，否则模拟实际请求
+func (c *Cors) Simulate(origin, method, target string, headers []string, preflight bool) (Decision, error) {
+	if target == "" {
+		target = "/"
+	}
+	r, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return Decision{}, err
+	}
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+	if preflight {
+		r.Method = http.MethodOptions
+		r.Header.Set("Access-Control-Request-Method", method)
+		if len(headers) > 0 {
+			r.Header.Set("Access-Control-Request-Headers", strings.Join(headers, ","))
+		}
+	} else if method != "" {
+		r.Method = method
+	}
+	return c.Evaluate(r), nil
+}
+
+type debugPage struct {
+	Policy   Policy    `json:"policy"`
+	Query    debugForm `json:"-"`
+	Decision *Decision `json:"decision,omitempty"`
+}
+
+type debugForm struct {
+	Origin    string
+	Method    string
+	Target    string
+	Headers   string
+	Preflight bool
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>CORS policy</title></head>
+<body>
+<h1>CORS policy</h1>
+<table>
+<tr><th align="left">Allowed origins</th><td>{{if .Policy.AllowedOriginsAll}}*{{else}}{{range .Policy.AllowedOrigins}}{{.}} {{end}}{{end}}</td></tr>
+<tr><th align="left">Wildcard origins</th><td>{{range .Policy.AllowedWildcardOrigins}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Origin func</th><td>{{.Policy.AllowOriginFunc}}</td></tr>
+<tr><th align="left">Allowed methods</th><td>{{if .Policy.AllowedMethodsAll}}* {{end}}{{range .Policy.AllowedMethods}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Allowed headers</th><td>{{if .Policy.AllowedHeadersAll}}* {{end}}{{range .Policy.AllowedHeaders}}{{.}} {{end}}{{range .Policy.AllowedHeaderPatterns}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Denied headers</th><td>{{range .Policy.DeniedHeaders}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Exposed headers</th><td>{{if .Policy.ExposedHeadersAll}}* {{end}}{{range .Policy.ExposedHeaders}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Credentials</th><td>{{.Policy.AllowCredentials}}</td></tr>
+<tr><th align="left">Max age</th><td>{{.Policy.MaxAge}}</td></tr>
+<tr><th align="left">Options passthrough</th><td>{{.Policy.OptionsPassthrough}}</td></tr>
+<tr><th align="left">Report only</th><td>{{.Policy.ReportOnly}}</td></tr>
+<tr><th align="left">Static response</th><td>{{.Policy.StaticResponse}}</td></tr>
+<tr><th align="left">Method lister</th><td>{{.Policy.MethodLister}}</td></tr>
+<tr><th align="left">Lenient methods</th><td>{{.Policy.LenientMethods}}</td></tr>
+<tr><th align="left">Block disallowed</th><td>{{.Policy.BlockDisallowed}}</td></tr>
+<tr><th align="left">Reject mode</th><td>{{.Policy.RejectMode}}</td></tr>
+<tr><th align="left">Private network</th><td>{{.Policy.PrivateNetwork}} {{range .Policy.PrivateNetworkOrigins}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Allow-Methods response</th><td>{{.Policy.AllowMethodsResponse}}</td></tr>
+<tr><th align="left">Allow-Headers response</th><td>{{.Policy.AllowHeadersResponse}}</td></tr>
+</table>
+<h2>Simulate</h2>
+<form method="get">
+<input type="hidden" name="simulate" value="1">
+<label>Origin <input name="origin" value="{{.Query.Origin}}"></label>
+<label>Method <input name="method" value="{{.Query.Method}}"></label>
+<label>URL <input name="url" value="{{.Query.Target}}"></label>
+<label>Headers <input name="headers" value="{{.Query.Headers}}"></label>
+<label><input type="checkbox" name="preflight" value="1"{{if .Query.Preflight}} checked{{end}}> Preflight</label>
+<input type="submit" value="Simulate">
+</form>
+{{if and .Decision .Policy.StaticResponse}}
+<p>Static response mode: every request receives Access-Control-Allow-Origin: * regardless of the decision below.</p>
+{{end}}
+{{with .Decision}}
+<h2>Decision</h2>
+<table>
+<tr><th align="left">Preflight</th><td>{{.Preflight}}</td></tr>
+<tr><th align="left">Origin</th><td>{{.Origin}}</td></tr>
+<tr><th align="left">Method</th><td>{{.Method}}</td></tr>
+<tr><th align="left">Headers</th><td>{{range .Headers}}{{.}} {{end}}</td></tr>
+<tr><th align="left">Allowed</th><td>{{.Allowed}}</td></tr>
+<tr><th align="left">Reason</th><td>{{.Reason}}</td></tr>
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// DebugHandler 返回一个展示当前生效策略的http.Handler，通常挂载在/debug/cors上。
+// 请求携带format=json参数或者Accept为application/json时以JSON格式返回，否则返回HTML页面。
+// 携带simulate参数时，会根据origin、method、url、headers和preflight参数模拟一个请求并展示判定结果
+func (c *Cors) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		q := r.URL.Query()
+		page := debugPage{
+			Policy: c.Policy(),
+			Query: debugForm{
+				Origin:    q.Get("origin"),
+				Method:    q.Get("method"),
+				Target:    q.Get("url"),
+				Headers:   q.Get("headers"),
+				Preflight: q.Get("preflight") != "",
+			},
+		}
+		if page.Query.Method == "" {
+			page.Query.Method = http.MethodGet
+		}
+		if q.Get("simulate") != "" {
+			var headers []string
+			for _, h := range strings.Split(page.Query.Headers, ",") {
+				if h = strings.TrimSpace(h); h != "" {
+					headers = append(headers, h)
+				}
+			}
+			d, err := c.Simulate(page.Query.Origin, page.Query.Method, page.Query.Target, headers, page.Query.Preflight)
+			if err != nil {
+				http.Error(w, "invalid url: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			page.Decision = &d
+		}
+
+		w.Header().Set("Cache-Control", "no-store")
+		if q.Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(page)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		debugTemplate.Execute(w, page)
+	})
+}
@@ -0,0 +1,125 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:   []string{"http://foobar.com", "http://*.bar.com"},
+		AllowedMethods:   []string{"get", "put"},
+		AllowCredentials: true,
+		MaxAge:           10,
+	})
+	p := s.Policy()
+	assert.DeepEqual(t, p.AllowedOrigins, []string{"http://foobar.com"})
+	assert.DeepEqual(t, p.AllowedWildcardOrigins, []string{"http://*.bar.com"})
+	assert.DeepEqual(t, p.AllowedMethods, []string{"GET", "PUT"})
+	assert.True(t, p.AllowCredentials)
+	assert.DeepEqual(t, p.MaxAge, 10)
+	assert.DeepEqual(t, p.RejectMode, "passthrough")
+	assert.DeepEqual(t, p.AllowMethodsResponse, "echo")
+
+	p = New(Options{
+		AllowedMethods:        []string{"GET", "PUT"},
+		AllowMethodsStyle:     ResponseList,
+		BlockDisallowed:       true,
+		RejectMode:            RejectForbidden,
+		PrivateNetworkOrigins: []string{"http://*.foobar.com"},
+		LenientMethods:        true,
+		MethodLister:          ServeMuxMethods(http.NewServeMux()),
+	}).Policy()
+	assert.True(t, p.LenientMethods)
+	assert.True(t, p.BlockDisallowed)
+	assert.True(t, p.MethodLister)
+	assert.DeepEqual(t, p.RejectMode, "forbidden")
+	assert.DeepEqual(t, p.AllowMethodsResponse, "GET, PUT")
+	assert.True(t, p.PrivateNetwork)
+	assert.DeepEqual(t, p.PrivateNetworkOrigins, []string{"http://*.foobar.com"})
+	assert.False(t, p.StaticResponse)
+
+	p = New(Options{
+		StaticResponse: true,
+		OnReject:       func(w http.ResponseWriter, r *http.Request, d Decision) {},
+	}).Policy()
+	assert.True(t, p.StaticResponse)
+	assert.DeepEqual(t, p.RejectMode, "custom")
+	assert.DeepEqual(t, p.AllowMethodsResponse, "GET, POST, HEAD")
+}
+
+func TestDebugHandlerStatic(t *testing.T) {
+	h := New(Options{StaticResponse: true}).DebugHandler()
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("GET", "/debug/cors?simulate=1&origin=http://foobar.com", nil))
+	assert.StringContains(t, res.Body.String(), "Static response mode")
+}
+
+func TestSimulate(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		AllowedHeaders: []string{"X-Header-1"},
+	})
+	simulate := func(origin, method string, headers []string, preflight bool) Decision {
+		d, err := s.Simulate(origin, method, "", headers, preflight)
+		assert.Nil(t, err)
+		return d
+	}
+	assert.True(t, simulate("http://foobar.com", "GET", []string{"X-Header-1"}, true).Allowed)
+	assert.DeepEqual(t, simulate("http://foobar.com", "GET", []string{"X-Header-2"}, true).Reason, ReasonHeadersNotAllowed)
+	assert.DeepEqual(t, simulate("http://foobar.com", "PUT", nil, false).Reason, ReasonMethodNotAllowed)
+	assert.DeepEqual(t, simulate("", "GET", nil, false).Reason, ReasonMissingOrigin)
+
+	_, err := s.Simulate("http://foobar.com", "GET", "%zz", nil, false)
+	assert.NotNil(t, err)
+}
+
+func TestSimulateTarget(t *testing.T) {
+	s := New(Options{MethodLister: ServeMuxMethods(newRouteMux())})
+
+	d, err := s.Simulate("http://foobar.com", "DELETE", "/items/1", nil, true)
+	assert.Nil(t, err)
+	assert.True(t, d.Allowed)
+	d, err = s.Simulate("http://foobar.com", "DELETE", "http://example.com/items", nil, true)
+	assert.Nil(t, err)
+	assert.DeepEqual(t, d.Reason, ReasonMethodNotAllowed)
+
+	res := httptest.NewRecorder()
+	s.DebugHandler().ServeHTTP(res, httptest.NewRequest("GET", "/debug/cors?format=json&simulate=1&origin=http://foobar.com&method=PUT&url=/items/1&preflight=1", nil))
+	assert.StringContains(t, res.Body.String(), `"allowed":true`)
+
+	res = httptest.NewRecorder()
+	s.DebugHandler().ServeHTTP(res, httptest.NewRequest("GET", "/debug/cors?simulate=1&url=%25zz", nil))
+	assert.DeepEqual(t, res.Code, http.StatusBadRequest)
+}
+
+func TestDebugHandler(t *testing.T) {
+	h := New(Options{AllowedOrigins: []string{"http://foobar.com"}}).DebugHandler()
+
+	res := httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("GET", "/debug/cors?format=json&simulate=1&origin=http://barbaz.com&method=GET", nil))
+	assert.DeepEqual(t, res.Header().Get("Content-Type"), "application/json")
+	var page struct {
+		Policy   Policy    `json:"policy"`
+		Decision *Decision `json:"decision"`
+	}
+	assert.Nil(t, json.Unmarshal(res.Body.Bytes(), &page))
+	assert.DeepEqual(t, page.Policy.AllowedOrigins, []string{"http://foobar.com"})
+	assert.NotNil(t, page.Decision)
+	assert.DeepEqual(t, page.Decision.Reason, ReasonOriginNotAllowed)
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("GET", "/debug/cors?simulate=1&origin=%3Cscript%3E&preflight=1", nil))
+	assert.DeepEqual(t, res.Header().Get("Content-Type"), "text/html; charset=utf-8")
+	assert.StringContains(t, res.Body.String(), "http://foobar.com")
+	assert.StringContains(t, res.Body.String(), "origin_not_allowed")
+	assert.StringDoesNotContain(t, res.Body.String(), "<script>")
+
+	res = httptest.NewRecorder()
+	h.ServeHTTP(res, httptest.NewRequest("POST", "/debug/cors", nil))
+	assert.DeepEqual(t, res.Code, http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,246 @@
+package cors
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Reason 描述CORS策略拒绝一个请求的原因
+type Reason string
+
+// 预定义的拒绝原因
+const (
+	// ReasonNone 表示请求没有被拒绝
+	ReasonNone Reason = ""
+	// ReasonMissingOrigin 表示请求没有携带Origin头部，因此不是跨域请求
+	ReasonMissingOrigin Reason = "missing_origin"
+	// ReasonOptionsRequest 表示请求是一个普通的OPTIONS请求，而不是预检请求
+	ReasonOptionsRequest Reason = "options_request"
+	// ReasonOriginNotAllowed 表示请求的源不被允许
+	ReasonOriginNotAllowed Reason = "origin_not_allowed"
+	// ReasonMethodNotAllowed 表示请求的方法不被允许
+	ReasonMethodNotAllowed Reason = "method_not_allowed"
+	// ReasonHeadersNotAllowed 表示请求的头部不被允许
+	ReasonHeadersNotAllowed Reason = "headers_not_allowed"
+	// ReasonInvalidMethod 表示请求的方法不是合法的token
+	ReasonInvalidMethod Reason = "invalid_method"
+	// ReasonInvalidHeaders 表示Access-Control-Request-Headers不是合法的token列表
+	ReasonInvalidHeaders Reason = "invalid_headers"
+	// ReasonPrivateNetworkNotAllowed 表示源不被允许发起私有网络访问
+	ReasonPrivateNetworkNotAllowed Reason = "private_network_not_allowed"
+	// ReasonVetoed 表示透传的预检请求被后续的处理程序否决
+	ReasonVetoed Reason = "vetoed"
+	// ReasonCrossSiteBlocked 表示跨站请求被资源隔离策略拒绝
+	ReasonCrossSiteBlocked Reason = "cross_site_blocked"
+)
+
+// Decision 是CORS策略对一个请求的判定结果
+type Decision struct {
+	// Preflight 指示该请求是否为预检请求
+	Preflight bool `json:"preflight"`
+
+	// Origin 是请求的源
+	Origin string `json:"origin"`
+
+	// OriginDerived 指示Origin是否是在请求没有Origin头部时从Referer头部推导出来的。
+	// 推导出的源只用于严格模式的拒绝判断，不会出现在Access-Control-Allow-Origin中
+	OriginDerived bool `json:"origin_derived,omitempty"`
+
+	// Method 是请求的方法，对于预检请求则是Access-Control-Request-Method的值
+	Method string `json:"method"`
+
+	// Headers 是预检请求通过Access-Control-Request-Headers请求的头部
+	Headers []string `json:"headers,omitempty"`
+
+	// RouteMethods 是路由为请求路径注册的方法，仅在配置了MethodLister并且请求的源被允许时设置
+	RouteMethods []string `json:"route_methods,omitempty"`
+
+	// PrivateNetwork 指示预检请求是否请求了私有网络访问
+	PrivateNetwork bool `json:"private_network,omitempty"`
+
+	// Allowed 指示策略是否允许该请求
+	Allowed bool `json:"allowed"`
+
+	// Reason 是请求被拒绝的原因，请求被允许时为空
+	Reason Reason `json:"reason,omitempty"`
+}
+
+// Violation 描述一个被CORS策略拒绝的跨域请求
+type Violation struct {
+	// Decision 是策略对请求的判定结果
+	Decision Decision
+
+	// Request 是被拒绝的请求
+	Request *http.Request
+
+	// ReportOnly 指示策略是否处于仅报告模式，此时请求实际上没有被拒绝
+	ReportOnly bool
+
+	// Time 是违规发生的时间
+	Time time.Time
+}
+
+// isViolation 判断该决策是否是一次需要报告的违规
+// 没有Origin的请求以及普通的OPTIONS请求都不是跨域请求，不视为违规
+func (d Decision) isViolation() bool {
+	return !d.Allowed && d.Origin != "" && d.Reason != ReasonOptionsRequest
+}
+
+// withoutDerivedOrigin 返回忽略从Referer推导出的源之后的判定结果。
+// 推导出的源只用于严格模式的拒绝判断，没有被拒绝的请求按照没有Origin的请求报告和比较
+func (d Decision) withoutDerivedOrigin() Decision {
+	if !d.OriginDerived {
+		return d
+	}
+	return Decision{
+		Method:       d.Method,
+		RouteMethods: d.RouteMethods,
+		Reason:       ReasonMissingOrigin,
+	}
+}
+
+func isPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Evaluate 计算CORS策略对给定请求的判定结果，但不会修改任何响应
+func (c *Cors) Evaluate(r *http.Request) Decision {
+	if isPreflight(r) {
+		return c.evaluatePreflight(r)
+	}
+	return c.evaluateActualRequest(r)
+}
+
+func (c *Cors) evaluatePreflight(r *http.Request) Decision {
+	d := Decision{
+		Preflight:      true,
+		Origin:         r.Header.Get("Origin"),
+		Method:         r.Header.Get("Access-Control-Request-Method"),
+		PrivateNetwork: r.Header.Get("Access-Control-Request-Private-Network") == "true",
+	}
+	headers, err := parseHeaderList(r.Header.Values("Access-Control-Request-Headers"))
+	d.Headers = headers
+
+	switch {
+	case d.Origin == "":
+		d.Reason = ReasonMissingOrigin
+	case !c.isOriginAllowed(d.Origin):
+		d.Reason = ReasonOriginNotAllowed
+	case !c.isValidMethod(d.Method):
+		d.Reason = ReasonInvalidMethod
+	case err != nil:
+		d.Reason = ReasonInvalidHeaders
+	case !c.isRouteMethodAllowed(r, &d):
+		d.Reason = ReasonMethodNotAllowed
+	case !c.areHeadersAllowed(d.Headers):
+		d.Reason = ReasonHeadersNotAllowed
+	case c.privateNetwork && d.PrivateNetwork && !c.isPrivateNetworkAllowed(d.Origin):
+		d.Reason = ReasonPrivateNetworkNotAllowed
+	default:
+		d.Allowed = true
+	}
+	return d
+}
+
+func (c *Cors) evaluateActualRequest(r *http.Request) Decision {
+	d := Decision{
+		Origin: r.Header.Get("Origin"),
+		Method: r.Method,
+	}
+
+	if d.Origin == "" && c.refererFallback && r.Method != http.MethodOptions {
+		if d.Origin = refererOrigin(r.Header.Get("Referer")); d.Origin != "" {
+			d.OriginDerived = true
+		}
+	}
+
+	switch {
+	case r.Method == http.MethodOptions:
+		d.Reason = ReasonOptionsRequest
+	case d.Origin == "":
+		d.Reason = ReasonMissingOrigin
+	case !c.isOriginAllowed(d.Origin):
+		d.Reason = ReasonOriginNotAllowed
+	case !c.isValidMethod(d.Method):
+		d.Reason = ReasonInvalidMethod
+	case !c.isRouteMethodAllowed(r, &d):
+		d.Reason = ReasonMethodNotAllowed
+	default:
+		d.Allowed = true
+	}
+	return d
+}
+
+// isRouteMethodAllowed 判断请求的方法是否被允许，配置了MethodLister时使用路由上注册的方法。
+// 查询路由的开销较大，因此只在源被允许之后才查询，结果保存在d.RouteMethods中
+func (c *Cors) isRouteMethodAllowed(r *http.Request, d *Decision) bool {
+	if c.methodLister == nil {
+		return c.isMethodAllowed(d.Method)
+	}
+	d.RouteMethods = c.routeMethods(r)
+	return c.methodAllowedIn(d.Method, d.RouteMethods)
+}
+
+// report 在请求被拒绝时通知OnViolation回调
+func (c *Cors) report(r *http.Request, d Decision) {
+	if d.isViolation() {
+		c.notify(r, d)
+	}
+}
+
+// reportViolations 按照本策略评估请求并报告违规，但不写入任何响应，用于ReportOnly模式
+func (c *Cors) reportViolations(r *http.Request) {
+	if c.skipSameOriginRequest(r) {
+		return
+	}
+	if c.isolation != nil {
+		if d := c.evaluateIsolation(r); !d.Allowed {
+			c.notify(r, d)
+			return
+		}
+	}
+	c.report(r, c.Evaluate(r).withoutDerivedOrigin())
+}
+
+// notify 将违规通知给OnViolation回调
+func (c *Cors) notify(r *http.Request, d Decision) {
+	if c.onViolation != nil {
+		c.onViolation(Violation{
+			Decision:   d,
+			Request:    r,
+			ReportOnly: c.reportOnly,
+			Time:       time.Now(),
+		})
+	}
+}
+
+// refererOrigin 从Referer头部推导出请求的源，只保留scheme、host和port
+func refererOrigin(referer string) string {
+	if referer == "" {
+		return ""
+	}
+	u, err := url.Parse(referer)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme != "http" && scheme != "https" {
+		return ""
+	}
+	return scheme + "://" + strings.ToLower(u.Host)
+}
+
+// normalizeMethod 规范化方法，LenientMethods开启时将方法转换为大写
+func (c *Cors) normalizeMethod(method string) string {
+	if c.lenientMethods {
+		return strings.ToUpper(method)
+	}
+	return normalizeMethod(method)
+}
+
+// isValidMethod 判断方法是否是合法的token，LenientMethods开启时不做校验
+func (c *Cors) isValidMethod(method string) bool {
+	return c.lenientMethods || isToken(method)
+}
@@ -0,0 +1,90 @@
+package cors
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// serveActual 调用next处理实际请求。开启AutoExposeHeaders并且响应带有CORS头部时，
+// 在响应头部发送之前将处理器添加的头部加入Access-Control-Expose-Headers
+func (c *Cors) serveActual(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	hook := c.autoExposeHook(w.Header(), w.Header())
+	if hook == nil {
+		next.ServeHTTP(w, r)
+		return
+	}
+	hw := newHookWriter(w, hook)
+	next.ServeHTTP(hw.wrap(), r)
+	hw.commit()
+}
+
+// autoExposeHook 返回自动暴露头部的hook，不需要自动暴露时返回nil。
+// added是中间件为请求添加的头部，existing中已有的头部不会被暴露
+func (c *Cors) autoExposeHook(added http.Header, existing ...http.Header) func(h http.Header) {
+	if !c.autoExpose || added.Get("Access-Control-Allow-Origin") == "" ||
+		added.Get("Access-Control-Expose-Headers") == "*" {
+		return nil
+	}
+
+	// 只暴露处理器添加的头部，中间件自身添加的头部不会被暴露
+	names := map[string]struct{}{}
+	for _, headers := range existing {
+		for name := range headers {
+			names[name] = struct{}{}
+		}
+	}
+	return func(h http.Header) {
+		c.exposeAddedHeaders(h, names)
+	}
+}
+
+// exposeAddedHeaders 将不在existing中并且允许自动暴露的头部合并到Access-Control-Expose-Headers
+func (c *Cors) exposeAddedHeaders(h http.Header, existing map[string]struct{}) {
+	var added []string
+	for name := range h {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		name = http.CanonicalHeaderKey(name)
+		if c.isAutoExposable(name) {
+			added = append(added, name)
+		}
+	}
+	if len(added) == 0 {
+		return
+	}
+	sort.Strings(added)
+
+	var exposed []string
+	if v := h.Get("Access-Control-Expose-Headers"); v != "" {
+		exposed = strings.Split(v, ", ")
+	}
+	seen := make(map[string]struct{}, len(exposed))
+	for _, name := range exposed {
+		seen[name] = struct{}{}
+	}
+	for _, name := range added {
+		if _, ok := seen[name]; !ok {
+			exposed = append(exposed, name)
+		}
+	}
+	h.Set("Access-Control-Expose-Headers", strings.Join(exposed, ", "))
+	c.logf("    Auto-exposed response headers: %v", added)
+}
+
+// isAutoExposable 判断一个响应头部是否可以被自动暴露。
+// 安全列表响应头部本来就可以被读取，Access-Control-*和Set-Cookie永远不会暴露给脚本
+func (c *Cors) isAutoExposable(name string) bool {
+	if isSafelistedResponseHeader(name) || strings.HasPrefix(name, "Access-Control-") ||
+		name == "Set-Cookie" || name == "Set-Cookie2" {
+		return false
+	}
+	if matchHeader(name, c.autoExposeDenied, c.autoExposeWDenied) {
+		return false
+	}
+	if len(c.autoExposeAllowed) == 0 && len(c.autoExposeWAllowed) == 0 {
+		return true
+	}
+	return matchHeader(name, c.autoExposeAllowed, c.autoExposeWAllowed)
+}
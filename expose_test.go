@@ -0,0 +1,97 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func exposingHandler(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Set("X-Request-Id", "abc")
+	h.Set("Link", "</next>; rel=next")
+	h.Set("X-RateLimit-Remaining", "10")
+	h.Set("X-Internal-Trace", "1")
+	h.Set("Content-Type", "text/plain")
+	h.Set("Set-Cookie", "a=b")
+	w.Write([]byte("hello"))
+}
+
+func TestAutoExposeHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:    []string{"http://foobar.com"},
+		ExposedHeaders:    []string{"X-Static", "Link"},
+		AutoExposeHeaders: true,
+		AutoExposeDenied:  []string{"X-Internal-*"},
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(exposingHandler)).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"),
+		"X-Static, Link, X-Ratelimit-Remaining, X-Request-Id")
+	assert.DeepEqual(t, res.Body.String(), "hello")
+
+	// 不被允许的源不会暴露任何头部
+	req.Header.Set("Origin", "http://barbaz.com")
+	res = httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(exposingHandler)).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), "")
+}
+
+func TestAutoExposeAllowed(t *testing.T) {
+	s := New(Options{
+		AutoExposeHeaders: true,
+		AutoExposeAllowed: []string{"X-RateLimit-*"},
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(exposingHandler)).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), "X-Ratelimit-Remaining")
+}
+
+func TestAutoExposeWithoutWrite(t *testing.T) {
+	s := New(Options{AutoExposeHeaders: true})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+	})).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), "X-Request-Id")
+}
+
+func TestAutoExposeFlush(t *testing.T) {
+	s := New(Options{AutoExposeHeaders: true})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-Id", "abc")
+		w.(http.Flusher).Flush()
+		// 头部已经发送，之后添加的头部不会被暴露
+		w.Header().Set("X-Late", "1")
+	})).ServeHTTP(res, req)
+	assert.True(t, res.Flushed)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), "X-Request-Id")
+}
+
+func TestAutoExposeWildcard(t *testing.T) {
+	s := New(Options{
+		ExposedHeaders:    []string{"*"},
+		AutoExposeHeaders: true,
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(exposingHandler)).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), "*")
+}
@@ -0,0 +1,124 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestResourceIsolation(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		ResourceIsolation: &IsolationPolicy{
+			AllowNavigation: true,
+			ExemptPaths:     []string{"/public/"},
+		},
+	})
+
+	cases := []struct {
+		name       string
+		method     string
+		path       string
+		reqHeaders map[string]string
+		status     int
+	}{
+		{"NoFetchMetadata", "POST", "/foo", map[string]string{}, http.StatusOK},
+		{"SameOrigin", "POST", "/foo", map[string]string{"Sec-Fetch-Site": "same-origin", "Sec-Fetch-Mode": "cors"}, http.StatusOK},
+		{"UserInitiated", "GET", "/foo", map[string]string{"Sec-Fetch-Site": "none", "Sec-Fetch-Mode": "navigate"}, http.StatusOK},
+		{"SameSite", "GET", "/foo", map[string]string{"Sec-Fetch-Site": "same-site", "Sec-Fetch-Mode": "no-cors"}, http.StatusOK},
+		{"CrossSiteNoCors", "GET", "/foo", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image"}, http.StatusForbidden},
+		{"CrossSiteExempt", "GET", "/public/logo.png", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image"}, http.StatusOK},
+		{"CrossSiteNavigation", "GET", "/foo", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "document"}, http.StatusOK},
+		{"CrossSiteNavigationPost", "POST", "/foo", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "document"}, http.StatusForbidden},
+		{"CrossSiteEmbed", "GET", "/foo", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "embed"}, http.StatusForbidden},
+		{"CrossSiteAllowedCors", "POST", "/foo", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "cors", "Origin": "http://foobar.com"}, http.StatusOK},
+		{"CrossSiteDisallowedCors", "POST", "/foo", map[string]string{"Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "cors", "Origin": "http://barbaz.com"}, http.StatusForbidden},
+		{"CrossSitePreflight", "OPTIONS", "/foo", map[string]string{
+			"Sec-Fetch-Site":                "cross-site",
+			"Sec-Fetch-Mode":                "cors",
+			"Origin":                        "http://foobar.com",
+			"Access-Control-Request-Method": "GET",
+		}, http.StatusOK},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com"+tc.path, nil)
+			for name, value := range tc.reqHeaders {
+				req.Header.Add(name, value)
+			}
+
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			assert.DeepEqual(t, res.Code, tc.status)
+
+			res = httptest.NewRecorder()
+			s.ServeHTTP(res, req, testHandler)
+			assert.DeepEqual(t, res.Code, tc.status)
+		})
+	}
+}
+
+func TestResourceIsolationBlockSameSite(t *testing.T) {
+	var violations []Violation
+	s := New(Options{
+		ResourceIsolation: &IsolationPolicy{BlockSameSite: true},
+		ReportOnly:        true,
+		OnViolation: func(v Violation) {
+			violations = append(violations, v)
+		},
+	})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Sec-Fetch-Site", "same-site")
+	req.Header.Set("Sec-Fetch-Mode", "no-cors")
+
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Body.String(), "hello")
+	assert.Len(t, violations, 1)
+	assert.DeepEqual(t, violations[0].Decision.Reason, ReasonCrossSiteBlocked)
+}
+
+func TestResourceIsolationVary(t *testing.T) {
+	s := New(Options{ResourceIsolation: &IsolationPolicy{}})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+	assert.DeepEqual(t, res.Header().Get("Vary"), "Sec-Fetch-Site, Sec-Fetch-Mode, Sec-Fetch-Dest, Origin")
+
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req.Header.Set("Sec-Fetch-Mode", "no-cors")
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusForbidden)
+	assert.StringContains(t, res.Header().Get("Vary"), "Sec-Fetch-Site, Sec-Fetch-Mode, Sec-Fetch-Dest")
+}
+
+func TestResourceIsolationReportOrigin(t *testing.T) {
+	var violations []Violation
+	s := New(Options{
+		AllowedOrigins:    []string{"http://foobar.com"},
+		ResourceIsolation: &IsolationPolicy{},
+		OnViolation: func(v Violation) {
+			violations = append(violations, v)
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	req.Header.Set("Referer", "http://foobar.com/page")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+
+	// 从Referer推导出的源不能让cors模式的请求通过
+	assert.DeepEqual(t, res.Code, http.StatusForbidden)
+	assert.Len(t, violations, 1)
+	assert.DeepEqual(t, violations[0].Decision.Origin, "http://foobar.com")
+	assert.True(t, violations[0].Decision.OriginDerived)
+}
@@ -0,0 +1,7 @@
+module github.com/gotoxu/cors
+
+go 1.22
+
+require github.com/gotoxu/assert v0.0.0-20180423043527-14269c482f09
+
+require github.com/davecgh/go-spew v1.1.1 // indirect
@@ -0,0 +1,100 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ConflictPolicy 决定LazyHeaders模式下处理器自己设置了Access-Control-*头部时如何处理
+type ConflictPolicy int
+
+const (
+	// ConflictMiddlewareWins 使用中间件计算的值覆盖处理器设置的值，
+	// 并移除处理器设置的、中间件没有授予的Access-Control-*头部
+	ConflictMiddlewareWins ConflictPolicy = iota
+
+	// ConflictHandlerWins 保留处理器设置的值
+	ConflictHandlerWins
+
+	// ConflictMerge 合并列表类型的头部（Access-Control-Expose-Headers、Access-Control-Allow-Methods、
+	// Access-Control-Allow-Headers），其他头部只能有一个值，仍然按照ConflictMiddlewareWins处理
+	ConflictMerge
+)
+
+// stagedWriter 暂存中间件添加的头部，在响应头部发送之前才将它们合并到真正的响应头部中
+type stagedWriter struct {
+	*hookWriter
+	staged http.Header
+}
+
+// Header 返回暂存的头部，中间件通过它添加的头部要等到响应头部发送时才生效
+func (w *stagedWriter) Header() http.Header {
+	return w.staged
+}
+
+// serveLazy 按照LazyHeaders模式处理实际请求并返回判定结果
+func (c *Cors) serveLazy(w http.ResponseWriter, r *http.Request, next http.Handler) Decision {
+	sw := &stagedWriter{staged: http.Header{}}
+	sw.hookWriter = newHookWriter(w, func(h http.Header) {
+		c.applyStaged(h, sw.staged)
+	})
+	// 请求被拒绝时拒绝响应通过sw写入，暂存的头部在写入时生效
+	d, blocked := c.handleActualRequest(sw, r, next != nil)
+	if blocked {
+		return d
+	}
+	if next == nil {
+		sw.commit()
+		return d
+	}
+
+	if hook := c.autoExposeHook(sw.staged, w.Header(), sw.staged); hook != nil {
+		sw.hooks = append(sw.hooks, hook)
+	}
+	next.ServeHTTP(sw.hookWriter.wrap(), r)
+	sw.commit()
+	return d
+}
+
+// applyStaged 将暂存的头部合并到响应头部。Vary总是合并，
+// Access-Control-*头部按照ConflictPolicy处理，其他头部只在处理器没有设置时添加
+func (c *Cors) applyStaged(dst, staged http.Header) {
+	if c.conflictPolicy != ConflictHandlerWins {
+		// 处理器不能授予中间件没有授予的权限，例如为被拒绝的源设置Access-Control-Allow-Origin
+		for name := range dst {
+			if _, ok := staged[name]; ok || !strings.HasPrefix(name, "Access-Control-") {
+				continue
+			}
+			if c.conflictPolicy == ConflictMerge && isListHeader(name) {
+				continue
+			}
+			c.logf("    Handler set '%s', removing header not granted by middleware", name)
+			delete(dst, name)
+		}
+	}
+	for name, values := range staged {
+		current, set := dst[name]
+		switch {
+		case !set:
+			dst[name] = append([]string(nil), values...)
+		case name == "Vary":
+			dst.Set(name, mergeVary(current, values))
+		case !strings.HasPrefix(name, "Access-Control-"):
+		case c.conflictPolicy == ConflictHandlerWins:
+			c.logf("    Handler set '%s', keeping handler value", name)
+		case c.conflictPolicy == ConflictMerge && isListHeader(name):
+			dst.Set(name, mergeList(current, values))
+		default:
+			c.logf("    Handler set '%s', overriding with middleware value", name)
+			dst[name] = append([]string(nil), values...)
+		}
+	}
+}
+
+func isListHeader(name string) bool {
+	switch name {
+	case "Access-Control-Expose-Headers", "Access-Control-Allow-Methods", "Access-Control-Allow-Headers":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestLazyHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		ExposedHeaders: []string{"X-Static"},
+		LazyHeaders:    true,
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// 处理器看不到也删除不了尚未添加的CORS头部
+		assert.DeepEqual(t, w.Header().Get("Access-Control-Allow-Origin"), "")
+		w.Header().Del("Vary")
+		w.Header().Set("Vary", "Accept-Encoding")
+		w.Write([]byte("hello"))
+	})).ServeHTTP(res, req)
+
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                          "Accept-Encoding, Origin",
+		"Access-Control-Allow-Origin":   "http://foobar.com",
+		"Access-Control-Expose-Headers": "X-Static",
+	})
+	assert.DeepEqual(t, res.Body.String(), "hello")
+}
+
+func TestLazyHeadersWithoutWrite(t *testing.T) {
+	s := New(Options{LazyHeaders: true})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "*")
+
+	res = httptest.NewRecorder()
+	s.HandlerFunc(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "*")
+}
+
+func TestLazyHeadersConflictPolicy(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "http://handler.com")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Handler, x-static")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		name   string
+		policy ConflictPolicy
+		origin string
+		expose string
+	}{
+		{"MiddlewareWins", ConflictMiddlewareWins, "http://foobar.com", "X-Static"},
+		{"HandlerWins", ConflictHandlerWins, "http://handler.com", "X-Handler, x-static"},
+		{"Merge", ConflictMerge, "http://foobar.com", "X-Handler, x-static"},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(Options{
+				AllowedOrigins: []string{"http://foobar.com"},
+				ExposedHeaders: []string{"X-Static"},
+				LazyHeaders:    true,
+				ConflictPolicy: tc.policy,
+			})
+			req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+			req.Header.Set("Origin", "http://foobar.com")
+			res := httptest.NewRecorder()
+			s.Handler(handler).ServeHTTP(res, req)
+			assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), tc.origin)
+			assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), tc.expose)
+		})
+	}
+}
+
+func TestLazyHeadersDisallowedOrigin(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("Origin"))
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Handler")
+		w.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		name   string
+		policy ConflictPolicy
+		origin string
+		expose string
+	}{
+		{"MiddlewareWins", ConflictMiddlewareWins, "", ""},
+		{"HandlerWins", ConflictHandlerWins, "http://barbaz.com", "X-Handler"},
+		{"Merge", ConflictMerge, "", "X-Handler"},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(Options{
+				AllowedOrigins:   []string{"http://foobar.com"},
+				AllowCredentials: true,
+				LazyHeaders:      true,
+				ConflictPolicy:   tc.policy,
+			})
+			req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+			req.Header.Set("Origin", "http://barbaz.com")
+			res := httptest.NewRecorder()
+			s.Handler(handler).ServeHTTP(res, req)
+			assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), tc.origin)
+			assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"), tc.expose)
+			if tc.origin == "" {
+				assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Credentials"), "")
+			}
+			assert.DeepEqual(t, res.Header().Get("Vary"), "Origin")
+		})
+	}
+}
+
+func TestLazyHeadersReject(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		BlockDisallowed: true,
+		RejectMode:      RejectForbidden,
+		LazyHeaders:     true,
+	})
+
+	req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://barbaz.com")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusForbidden)
+	assert.DeepEqual(t, res.Header().Get("Vary"), "Origin")
+}
+
+func TestLazyHeadersAutoExpose(t *testing.T) {
+	s := New(Options{
+		TimingAllowOrigin: true,
+		LazyHeaders:       true,
+		AutoExposeHeaders: true,
+	})
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(http.HandlerFunc(exposingHandler)).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Expose-Headers"),
+		"Link, X-Internal-Trace, X-Ratelimit-Remaining, X-Request-Id")
+}
@@ -0,0 +1,283 @@
+package cors
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// OriginStats 是学习模式下记录的某个源的访问统计
+type OriginStats struct {
+	Origin    string    `json:"origin"`
+	Count     uint64    `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	Methods   []string  `json:"methods"`
+	Headers   []string  `json:"headers"`
+}
+
+// Collapse 是建议用一个通配符源代替的一组源
+type Collapse struct {
+	Pattern string   `json:"pattern"`
+	Origins []string `json:"origins"`
+}
+
+// Proposal 是学习模式根据观察到的请求建议的策略
+type Proposal struct {
+	AllowedOrigins []string   `json:"allowed_origins"`
+	AllowedMethods []string   `json:"allowed_methods"`
+	AllowedHeaders []string   `json:"allowed_headers"`
+	Collapsed      []Collapse `json:"collapsed,omitempty"`
+}
+
+// Options 将建议的策略转换为Options
+func (p Proposal) Options() Options {
+	return Options{
+		AllowedOrigins: p.AllowedOrigins,
+		AllowedMethods: p.AllowedMethods,
+		AllowedHeaders: p.AllowedHeaders,
+	}
+}
+
+type originRecord struct {
+	count     uint64
+	firstSeen time.Time
+	lastSeen  time.Time
+	methods   map[string]struct{}
+	headers   map[string]struct{}
+}
+
+// Learner 记录所有访问过服务的源，用于为遗留服务建立允许列表。
+// 将它设置为Options.Learner即可开启学习模式
+type Learner struct {
+	// CollapseThreshold 是建议使用通配符代替时同一站点下子域名的最小数量，默认为3
+	CollapseThreshold int
+
+	// MaxOrigins 是记录的最大源数量，超出后新的源不再被记录，默认为10000
+	MaxOrigins int
+
+	// Site 返回主机所属的站点，用于将同一站点下的子域名合并为通配符源。
+	// 默认取主机的最后两个标签，因此co.uk、github.io这类公共后缀下不相关的站点也会被合并，
+	// 需要准确结果时可以设置为publicsuffix.EffectiveTLDPlusOne
+	Site func(host string) (string, error)
+
+	overflowed uint64
+
+	mu      sync.Mutex
+	origins map[string]*originRecord
+}
+
+// NewLearner 创建一个新的Learner
+func NewLearner() *Learner {
+	return &Learner{
+		CollapseThreshold: 3,
+		MaxOrigins:        10000,
+		origins:           map[string]*originRecord{},
+	}
+}
+
+// Observe 记录一个请求，没有Origin的请求将被忽略
+func (l *Learner) Observe(r *http.Request) {
+	if isPreflight(r) {
+		headers, _ := parseHeaderList(r.Header.Values("Access-Control-Request-Headers"))
+		l.record(Decision{
+			Origin:  r.Header.Get("Origin"),
+			Method:  r.Header.Get("Access-Control-Request-Method"),
+			Headers: headers,
+		})
+	} else {
+		l.record(Decision{Origin: r.Header.Get("Origin"), Method: r.Method})
+	}
+}
+
+func (l *Learner) record(d Decision) {
+	if d.Origin == "" {
+		return
+	}
+	origin := strings.ToLower(d.Origin)
+	now := time.Now()
+	maxOrigins := l.MaxOrigins
+	if maxOrigins <= 0 {
+		maxOrigins = 10000
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.origins == nil {
+		l.origins = map[string]*originRecord{}
+	}
+	rec, ok := l.origins[origin]
+	if !ok {
+		// Origin由客户端发送，限制记录数以避免内存无限增长
+		if len(l.origins) >= maxOrigins {
+			atomic.AddUint64(&l.overflowed, 1)
+			return
+		}
+		rec = &originRecord{
+			firstSeen: now,
+			methods:   map[string]struct{}{},
+			headers:   map[string]struct{}{},
+		}
+		l.origins[origin] = rec
+	}
+	rec.count++
+	rec.lastSeen = now
+	if d.Method != "" {
+		rec.methods[normalizeMethod(d.Method)] = struct{}{}
+	}
+	for _, h := range d.Headers {
+		rec.headers[h] = struct{}{}
+	}
+}
+
+// Reset 清除所有记录
+func (l *Learner) Reset() {
+	l.mu.Lock()
+	l.origins = map[string]*originRecord{}
+	atomic.StoreUint64(&l.overflowed, 0)
+	l.mu.Unlock()
+}
+
+// Overflowed 返回因为记录的源数量达到MaxOrigins而没有被记录的请求数
+func (l *Learner) Overflowed() uint64 {
+	return atomic.LoadUint64(&l.overflowed)
+}
+
+// Origins 返回所有记录的源，按源排序
+func (l *Learner) Origins() []OriginStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]OriginStats, 0, len(l.origins))
+	for origin, rec := range l.origins {
+		out = append(out, OriginStats{
+			Origin:    origin,
+			Count:     rec.count,
+			FirstSeen: rec.firstSeen,
+			LastSeen:  rec.lastSeen,
+			Methods:   sortedKeys(rec.methods),
+			Headers:   sortedKeys(rec.headers),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Origin < out[j].Origin })
+	return out
+}
+
+// Propose 根据记录的源建议一个策略。
+// 同一站点下的子域名数量达到CollapseThreshold时，建议用通配符源代替它们，站点由Site计算
+func (l *Learner) Propose() Proposal {
+	stats := l.Origins()
+	methods := map[string]struct{}{}
+	headers := map[string]struct{}{}
+	groups := map[string][]string{}
+	var origins []string
+	site := l.Site
+	if site == nil {
+		site = lastTwoLabels
+	}
+
+	for _, s := range stats {
+		for _, m := range s.Methods {
+			methods[m] = struct{}{}
+		}
+		for _, h := range s.Headers {
+			headers[h] = struct{}{}
+		}
+		// 不透明源"null"不应当出现在允许列表中
+		if s.Origin == "null" {
+			continue
+		}
+		if pattern, ok := collapsePattern(s.Origin, site); ok {
+			groups[pattern] = append(groups[pattern], s.Origin)
+		} else {
+			origins = append(origins, s.Origin)
+		}
+	}
+
+	threshold := l.CollapseThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+	p := Proposal{}
+	for pattern, members := range groups {
+		if len(members) >= threshold {
+			origins = append(origins, pattern)
+			p.Collapsed = append(p.Collapsed, Collapse{Pattern: pattern, Origins: members})
+		} else {
+			origins = append(origins, members...)
+		}
+	}
+	sort.Strings(origins)
+	sort.Slice(p.Collapsed, func(i, j int) bool { return p.Collapsed[i].Pattern < p.Collapsed[j].Pattern })
+
+	p.AllowedOrigins = origins
+	p.AllowedMethods = sortedKeys(methods)
+	p.AllowedHeaders = sortedKeys(headers)
+	return p
+}
+
+// ProposedConfig 以JSON格式返回建议的策略
+func (l *Learner) ProposedConfig() ([]byte, error) {
+	return json.MarshalIndent(l.Propose(), "", "  ")
+}
+
+// ServeHTTP 以JSON格式返回记录的源和建议的策略
+func (l *Learner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Origins  []OriginStats `json:"origins"`
+		Proposal Proposal      `json:"proposal"`
+	}{l.Origins(), l.Propose()})
+}
+
+// collapsePattern 返回可以匹配给定源的通配符源，源的主机不是某个站点的子域名时返回false
+func collapsePattern(origin string, siteOf func(host string) (string, error)) (string, bool) {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", false
+	}
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return "", false
+	}
+	// 主机就是站点本身时无法合并
+	site, err := siteOf(host)
+	if err != nil || site == "" || site == host || !strings.HasSuffix(host, "."+site) {
+		return "", false
+	}
+	if port := u.Port(); port != "" {
+		site += ":" + port
+	}
+	return u.Scheme + "://*." + site, true
+}
+
+// lastTwoLabels 是默认的站点计算方式，取主机的最后两个标签
+func lastTwoLabels(host string) (string, error) {
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return "", errors.New("cors: host " + strconv.Quote(host) + " has no site")
+	}
+	return strings.Join(labels[len(labels)-2:], "."), nil
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,154 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestLearner(t *testing.T) {
+	l := NewLearner()
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		Learner:        l,
+	})
+
+	requests := []struct {
+		method     string
+		reqHeaders map[string]string
+	}{
+		{"GET", map[string]string{"Origin": "https://a.example.com"}},
+		{"GET", map[string]string{"Origin": "https://b.example.com"}},
+		{"OPTIONS", map[string]string{
+			"Origin":                         "https://c.example.com",
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "x-header-1",
+		}},
+		{"POST", map[string]string{"Origin": "https://A.example.com"}},
+		{"GET", map[string]string{"Origin": "http://foobar.com"}},
+		{"GET", map[string]string{"Origin": "https://x.other.com"}},
+		{"GET", map[string]string{"Origin": "null"}},
+		{"GET", map[string]string{}},
+	}
+	for _, rr := range requests {
+		req, _ := http.NewRequest(rr.method, "http://example.com/foo", nil)
+		for name, value := range rr.reqHeaders {
+			req.Header.Add(name, value)
+		}
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	origins := l.Origins()
+	assert.Len(t, origins, 6)
+	assert.DeepEqual(t, origins[1].Origin, "https://a.example.com")
+	assert.DeepEqual(t, origins[1].Count, uint64(2))
+	assert.DeepEqual(t, origins[1].Methods, []string{"GET", "POST"})
+	assert.DeepEqual(t, origins[3].Headers, []string{"X-Header-1"})
+
+	p := l.Propose()
+	assert.DeepEqual(t, p.AllowedOrigins, []string{"http://foobar.com", "https://*.example.com", "https://x.other.com"})
+	assert.DeepEqual(t, p.AllowedMethods, []string{"GET", "POST", "PUT"})
+	assert.DeepEqual(t, p.AllowedHeaders, []string{"X-Header-1"})
+	assert.DeepEqual(t, p.Collapsed, []Collapse{{
+		Pattern: "https://*.example.com",
+		Origins: []string{"https://a.example.com", "https://b.example.com", "https://c.example.com"},
+	}})
+
+	// 建议的策略应当允许所有观察到的源
+	proposed := New(p.Options())
+	for _, o := range origins[:len(origins)-1] {
+		assert.True(t, proposed.isOriginAllowed(o.Origin), o.Origin)
+	}
+	assert.False(t, proposed.isOriginAllowed("null"))
+
+	l.CollapseThreshold = 4
+	assert.Len(t, l.Propose().AllowedOrigins, 5)
+
+	l.Reset()
+	assert.Empty(t, l.Origins())
+}
+
+func TestLearnerHandler(t *testing.T) {
+	l := NewLearner()
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	l.Observe(req)
+
+	res := httptest.NewRecorder()
+	l.ServeHTTP(res, httptest.NewRequest("GET", "/debug/cors/origins", nil))
+	assert.DeepEqual(t, res.Header().Get("Content-Type"), "application/json")
+
+	var body struct {
+		Origins  []OriginStats `json:"origins"`
+		Proposal Proposal      `json:"proposal"`
+	}
+	assert.Nil(t, json.Unmarshal(res.Body.Bytes(), &body))
+	assert.DeepEqual(t, body.Origins[0].Origin, "http://foobar.com")
+	assert.DeepEqual(t, body.Proposal.AllowedOrigins, []string{"http://foobar.com"})
+
+	config, err := l.ProposedConfig()
+	assert.Nil(t, err)
+	assert.StringContains(t, string(config), `"allowed_origins"`)
+}
+
+// coUKSite 模拟只知道co.uk这一个公共后缀的站点计算方式
+func coUKSite(host string) (string, error) {
+	if strings.HasSuffix(host, ".co.uk") {
+		labels := strings.Split(host, ".")
+		return strings.Join(labels[len(labels)-3:], "."), nil
+	}
+	return lastTwoLabels(host)
+}
+
+func TestCollapsePattern(t *testing.T) {
+	p, ok := collapsePattern("https://api.example.com:8443", lastTwoLabels)
+	assert.True(t, ok)
+	assert.DeepEqual(t, p, "https://*.example.com:8443")
+
+	_, ok = collapsePattern("https://example.com", lastTwoLabels)
+	assert.False(t, ok)
+	_, ok = collapsePattern("http://10.0.0.1", lastTwoLabels)
+	assert.False(t, ok)
+	_, ok = collapsePattern("http://localhost", lastTwoLabels)
+	assert.False(t, ok)
+
+	// 默认的方式不认识公共后缀
+	p, ok = collapsePattern("https://example.co.uk", lastTwoLabels)
+	assert.True(t, ok)
+	assert.DeepEqual(t, p, "https://*.co.uk")
+
+	p, ok = collapsePattern("https://a.b.example.co.uk", coUKSite)
+	assert.True(t, ok)
+	assert.DeepEqual(t, p, "https://*.example.co.uk")
+	_, ok = collapsePattern("https://example.co.uk", coUKSite)
+	assert.False(t, ok)
+}
+
+func TestLearnerSite(t *testing.T) {
+	l := NewLearner()
+	l.Site = coUKSite
+	for _, origin := range []string{"https://foo.co.uk", "https://bar.co.uk", "https://baz.co.uk"} {
+		l.record(Decision{Origin: origin, Method: "GET"})
+	}
+	p := l.Propose()
+	assert.Empty(t, p.Collapsed)
+	assert.DeepEqual(t, p.AllowedOrigins, []string{"https://bar.co.uk", "https://baz.co.uk", "https://foo.co.uk"})
+}
+
+func TestLearnerZeroValueAndLimit(t *testing.T) {
+	l := &Learner{MaxOrigins: 2}
+	for _, origin := range []string{"http://a.com", "http://b.com", "http://c.com", "http://a.com"} {
+		l.record(Decision{Origin: origin, Method: "GET"})
+	}
+	assert.Len(t, l.Origins(), 2)
+	assert.DeepEqual(t, l.Origins()[0].Count, uint64(2))
+	assert.DeepEqual(t, l.Overflowed(), uint64(1))
+
+	l.Reset()
+	assert.Empty(t, l.Origins())
+	assert.DeepEqual(t, l.Overflowed(), uint64(0))
+}
@@ -0,0 +1,86 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// RejectMode 决定如何响应被CORS策略拒绝的请求
+type RejectMode int
+
+const (
+	// RejectPassthrough 保持默认行为，被拒绝的预检请求以没有CORS头部的响应结束
+	RejectPassthrough RejectMode = iota
+
+	// RejectForbidden 以403和描述拒绝原因的JSON响应被拒绝的请求
+	RejectForbidden
+)
+
+// RejectHeader 是开启RejectDiagnostics时描述拒绝原因的诊断头部
+const RejectHeader = "X-Cors-Reject-Reason"
+
+// Rejection 是RejectForbidden模式下拒绝响应的JSON内容
+type Rejection struct {
+	Error     string `json:"error"`
+	Reason    Reason `json:"reason"`
+	Origin    string `json:"origin,omitempty"`
+	Method    string `json:"method,omitempty"`
+	Preflight bool   `json:"preflight"`
+}
+
+// reject 按照配置响应被拒绝的预检请求，如果已经写入了拒绝响应则返回true
+func (c *Cors) reject(w http.ResponseWriter, r *http.Request, d Decision) bool {
+	if !d.isViolation() {
+		return false
+	}
+	if c.onReject == nil && c.rejectMode != RejectForbidden {
+		return false
+	}
+	c.writeReject(w, r, d)
+	return true
+}
+
+// writeReject 使用OnReject或者403响应被拒绝的请求
+func (c *Cors) writeReject(w http.ResponseWriter, r *http.Request, d Decision) {
+	if c.onReject != nil {
+		c.logf("    Request rejected by OnReject: %s", d.Reason)
+		c.onReject(w, r, d)
+		return
+	}
+	c.logf("    Request rejected with %d: %s", http.StatusForbidden, d.Reason)
+	c.writeRejection(w, d)
+}
+
+// shouldBlock 判断严格模式下是否应当拒绝一个不被允许的实际请求
+func (c *Cors) shouldBlock(r *http.Request, d Decision) bool {
+	if !c.blockDisallowed || !d.isViolation() {
+		return false
+	}
+	method := c.normalizeMethod(d.Method)
+	for _, m := range c.safeMethods {
+		if m == method {
+			return false
+		}
+	}
+	if c.allowSameOrigin && c.isSameOrigin(r, d.Origin) {
+		return false
+	}
+	return true
+}
+
+func (c *Cors) writeRejection(w http.ResponseWriter, d Decision) {
+	headers := w.Header()
+	if c.rejectDiagnostics {
+		headers.Set(RejectHeader, string(d.Reason))
+	}
+	headers.Set("Content-Type", "application/json")
+	headers.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(Rejection{
+		Error:     "cors_rejected",
+		Reason:    d.Reason,
+		Origin:    d.Origin,
+		Method:    d.Method,
+		Preflight: d.Preflight,
+	})
+}
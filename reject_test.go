@@ -0,0 +1,258 @@
+package cors
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func newPreflight(origin, method string) *http.Request {
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/foo", nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", method)
+	return req
+}
+
+func TestRejectPassthrough(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"http://foobar.com"}})
+
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://barbaz.com", "GET"))
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "")
+}
+
+func TestRejectForbidden(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:    []string{"http://foobar.com"},
+		RejectMode:        RejectForbidden,
+		RejectDiagnostics: true,
+	})
+
+	for name, serve := range map[string]func(w http.ResponseWriter, r *http.Request){
+		"Handler":     s.Handler(testHandler).ServeHTTP,
+		"HandlerFunc": s.HandlerFunc,
+		"ServeHTTP": func(w http.ResponseWriter, r *http.Request) {
+			s.ServeHTTP(w, r, testHandler)
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res := httptest.NewRecorder()
+			serve(res, newPreflight("http://barbaz.com", "GET"))
+			assert.DeepEqual(t, res.Code, http.StatusForbidden)
+			assert.DeepEqual(t, res.Header().Get(RejectHeader), string(ReasonOriginNotAllowed))
+			assert.DeepEqual(t, res.Header().Get("Content-Type"), "application/json")
+
+			var body Rejection
+			assert.Nil(t, json.Unmarshal(res.Body.Bytes(), &body))
+			assert.DeepEqual(t, body, Rejection{
+				Error:     "cors_rejected",
+				Reason:    ReasonOriginNotAllowed,
+				Origin:    "http://barbaz.com",
+				Method:    "GET",
+				Preflight: true,
+			})
+		})
+	}
+
+	// 允许的预检请求以及普通请求不受影响
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://foobar.com", "GET"))
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+	assert.DeepEqual(t, res.Header().Get(RejectHeader), "")
+}
+
+func TestRejectWithoutDiagnostics(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		RejectMode:     RejectForbidden,
+	})
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://barbaz.com", "GET"))
+	assert.DeepEqual(t, res.Code, http.StatusForbidden)
+	assert.DeepEqual(t, res.Header().Get(RejectHeader), "")
+}
+
+func TestOnReject(t *testing.T) {
+	var rejected Decision
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		AllowedMethods: []string{"GET"},
+		RejectMode:     RejectForbidden,
+		OnReject: func(w http.ResponseWriter, r *http.Request, d Decision) {
+			rejected = d
+			w.WriteHeader(http.StatusTeapot)
+		},
+	})
+
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://foobar.com", "PUT"))
+	assert.DeepEqual(t, res.Code, http.StatusTeapot)
+	assert.DeepEqual(t, rejected.Reason, ReasonMethodNotAllowed)
+}
+
+func TestBlockDisallowed(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		AllowedMethods:  []string{"GET", "POST"},
+		BlockDisallowed: true,
+		AllowSameOrigin: true,
+	})
+
+	cases := []struct {
+		name    string
+		method  string
+		origin  string
+		blocked bool
+	}{
+		{"AllowedOrigin", "POST", "http://foobar.com", false},
+		{"DisallowedOrigin", "POST", "http://barbaz.com", true},
+		{"DisallowedMethod", "DELETE", "http://foobar.com", true},
+		{"SafeMethod", "GET", "http://barbaz.com", false},
+		{"SameOrigin", "POST", "http://example.com", false},
+		{"NoOrigin", "POST", "", false},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, "http://example.com/foo", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			if tc.blocked {
+				assert.DeepEqual(t, res.Code, http.StatusForbidden)
+				assert.StringDoesNotContain(t, res.Body.String(), "hello")
+			} else {
+				assert.DeepEqual(t, res.Body.String(), "hello")
+			}
+
+			res = httptest.NewRecorder()
+			s.ServeHTTP(res, req, testHandler)
+			if tc.blocked {
+				assert.DeepEqual(t, res.Code, http.StatusForbidden)
+			} else {
+				assert.DeepEqual(t, res.Body.String(), "hello")
+			}
+		})
+	}
+}
+
+func TestBlockDisallowedHandlerFunc(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		BlockDisallowed: true,
+	})
+	req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://barbaz.com")
+
+	// HandlerFunc无法阻止调用者继续处理请求，因此不写入拒绝响应
+	res := httptest.NewRecorder()
+	s.HandlerFunc(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+	assert.DeepEqual(t, res.Body.String(), "")
+	assertHeaders(t, res.Header(), map[string]string{"Vary": "Origin"})
+}
+
+func TestBlockDisallowedSafeMethods(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		BlockDisallowed: true,
+		SafeMethods:     []string{"head"},
+	})
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://example.com")
+
+	// 未开启AllowSameOrigin时同源请求同样被拒绝
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusForbidden)
+
+	req.Method = "HEAD"
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+}
+
+func TestBlockDisallowedReportOnly(t *testing.T) {
+	var violations []Violation
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		BlockDisallowed: true,
+		ReportOnly:      true,
+		OnViolation: func(v Violation) {
+			violations = append(violations, v)
+		},
+	})
+	req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://barbaz.com")
+
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Body.String(), "hello")
+	assert.Len(t, violations, 1)
+}
+
+func TestRefererFallback(t *testing.T) {
+	var violations []Violation
+	s := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		BlockDisallowed: true,
+		AllowSameOrigin: true,
+		RefererFallback: true,
+		OnViolation: func(v Violation) {
+			violations = append(violations, v)
+		},
+	})
+
+	cases := []struct {
+		name    string
+		referer string
+		blocked bool
+	}{
+		{"DisallowedReferer", "http://barbaz.com/page", true},
+		{"AllowedReferer", "http://foobar.com/page", false},
+		{"SameOriginReferer", "http://example.com/form", false},
+		{"NoReferer", "", false},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+			if tc.referer != "" {
+				req.Header.Set("Referer", tc.referer)
+			}
+
+			d := s.Evaluate(req)
+			assert.DeepEqual(t, d.OriginDerived, tc.referer != "")
+
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			if tc.blocked {
+				assert.DeepEqual(t, res.Code, http.StatusForbidden)
+			} else {
+				assert.DeepEqual(t, res.Body.String(), "hello")
+			}
+			// 推导出的源不会出现在响应头部中
+			assertHeaders(t, res.Header(), map[string]string{"Vary": "Origin"})
+		})
+	}
+
+	assert.True(t, violations[0].Decision.OriginDerived)
+	assert.DeepEqual(t, violations[0].Decision.Origin, "http://barbaz.com")
+
+	// 没有被拒绝的请求不会因为推导出的源而被报告，例如从其他站点点击链接
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Referer", "https://www.google.com/search?q=foo")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Body.String(), "hello")
+	assert.Len(t, violations, 1)
+}
@@ -0,0 +1,429 @@
+package cors
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReportType 是CORS违规报告的类型
+const ReportType = "cors-violation"
+
+// Report 是一个符合Reporting API格式的CORS违规报告
+type Report struct {
+	Type      string     `json:"type"`
+	Age       int64      `json:"age"`
+	URL       string     `json:"url"`
+	UserAgent string     `json:"user_agent,omitempty"`
+	Body      ReportBody `json:"body"`
+}
+
+// ReportBody 是CORS违规报告的内容
+type ReportBody struct {
+	Origin    string `json:"origin"`
+	Method    string `json:"method"`
+	Preflight bool   `json:"preflight"`
+	Reason    Reason `json:"reason"`
+
+	// Disposition 为"enforce"表示请求已被拒绝，为"report"表示策略处于仅报告模式
+	Disposition string `json:"disposition"`
+
+	// Timestamp 是违规发生的时间，单位为Unix毫秒
+	Timestamp int64 `json:"timestamp"`
+}
+
+// NewReport 基于给定的违规创建一个报告
+func NewReport(v Violation) Report {
+	disposition := "enforce"
+	if v.ReportOnly {
+		disposition = "report"
+	}
+	rep := Report{
+		Type: ReportType,
+		Body: ReportBody{
+			Origin:      v.Decision.Origin,
+			Method:      v.Decision.Method,
+			Preflight:   v.Decision.Preflight,
+			Reason:      v.Decision.Reason,
+			Disposition: disposition,
+			Timestamp:   v.Time.UnixNano() / int64(time.Millisecond),
+		},
+	}
+	if r := v.Request; r != nil {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		rep.URL = scheme + "://" + r.Host + r.URL.Path
+		rep.UserAgent = r.UserAgent()
+	}
+	return rep
+}
+
+// ReporterOptions 是配置Reporter的一个容器
+type ReporterOptions struct {
+	// Endpoint 是接收报告的URL
+	Endpoint string
+
+	// Client 是发送报告使用的HTTP客户端，默认为http.DefaultClient
+	Client *http.Client
+
+	// BatchSize 是每次发送的最大报告数，默认为100
+	BatchSize int
+
+	// FlushInterval 是定时发送报告的间隔，默认为10秒。每次发送的超时时间同样为FlushInterval
+	FlushInterval time.Duration
+
+	// MaxBuffered 是缓冲区中等待发送的最大报告数，超出的报告将被丢弃，默认为1000
+	MaxBuffered int
+}
+
+// Reporter 将CORS违规以Reporting API格式批量发送到指定的地址。
+// 它的Report方法可以直接用作Options.OnViolation
+type Reporter struct {
+	endpoint    string
+	client      *http.Client
+	batchSize   int
+	maxBuffered int
+	timeout     time.Duration
+
+	mu      sync.Mutex
+	buf     []Report
+	dropped uint64
+
+	flush chan struct{}
+	done  chan struct{}
+	wg    sync.WaitGroup
+	once  sync.Once
+}
+
+// NewReporter 基于给定的options创建一个新的Reporter并启动后台发送
+func NewReporter(options ReporterOptions) *Reporter {
+	rp := &Reporter{
+		endpoint:    options.Endpoint,
+		client:      options.Client,
+		batchSize:   options.BatchSize,
+		maxBuffered: options.MaxBuffered,
+		flush:       make(chan struct{}, 1),
+		done:        make(chan struct{}),
+	}
+	if rp.client == nil {
+		rp.client = http.DefaultClient
+	}
+	if rp.batchSize <= 0 {
+		rp.batchSize = 100
+	}
+	if rp.maxBuffered <= 0 {
+		rp.maxBuffered = 1000
+	}
+	interval := options.FlushInterval
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	// 报告地址没有响应时不能让后台发送和Close一直阻塞
+	rp.timeout = interval
+
+	rp.wg.Add(1)
+	go rp.run(interval)
+	return rp
+}
+
+// Report 将一个违规加入发送缓冲区，缓冲区已满时该违规将被丢弃
+func (rp *Reporter) Report(v Violation) {
+	rep := NewReport(v)
+
+	rp.mu.Lock()
+	if len(rp.buf) >= rp.maxBuffered {
+		rp.dropped++
+		rp.mu.Unlock()
+		return
+	}
+	rp.buf = append(rp.buf, rep)
+	full := len(rp.buf) >= rp.batchSize
+	rp.mu.Unlock()
+
+	if full {
+		select {
+		case rp.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Dropped 返回因为缓冲区已满或发送失败而被丢弃的报告数
+func (rp *Reporter) Dropped() uint64 {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.dropped
+}
+
+// Flush 立即发送缓冲区中的所有报告
+func (rp *Reporter) Flush() error {
+	var err error
+	for {
+		rp.mu.Lock()
+		n := len(rp.buf)
+		if n > rp.batchSize {
+			n = rp.batchSize
+		}
+		batch := rp.buf[:n:n]
+		rp.buf = rp.buf[n:]
+		rp.mu.Unlock()
+
+		if len(batch) == 0 {
+			return err
+		}
+		if e := rp.send(batch); e != nil {
+			rp.mu.Lock()
+			rp.dropped += uint64(len(batch))
+			rp.mu.Unlock()
+			err = e
+		}
+	}
+}
+
+// Close 停止后台发送，并发送缓冲区中剩余的报告
+func (rp *Reporter) Close() error {
+	rp.once.Do(func() {
+		close(rp.done)
+	})
+	rp.wg.Wait()
+	return rp.Flush()
+}
+
+func (rp *Reporter) run(interval time.Duration) {
+	defer rp.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-rp.done:
+			return
+		case <-ticker.C:
+		case <-rp.flush:
+		}
+		rp.Flush()
+	}
+}
+
+func (rp *Reporter) send(batch []Report) error {
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for i := range batch {
+		batch[i].Age = now - batch[i].Body.Timestamp
+	}
+
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), rp.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rp.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/reports+json")
+	res, err := rp.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("cors: report endpoint responded %s", res.Status)
+	}
+	return nil
+}
+
+// CollectorEntry 是Collector按源和原因聚合的违规统计
+type CollectorEntry struct {
+	Origin    string    `json:"origin"`
+	Reason    Reason    `json:"reason"`
+	Count     uint64    `json:"count"`
+	LastURL   string    `json:"last_url"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// CollectorSummary 是Collector收到的所有报告的汇总
+type CollectorSummary struct {
+	// Accepted 是通过校验的报告数
+	Accepted uint64 `json:"accepted"`
+
+	// Rejected 是未通过校验的报告数
+	Rejected uint64 `json:"rejected"`
+
+	// Overflowed 是因为聚合条目数达到MaxEntries而没有被统计的报告数
+	Overflowed uint64 `json:"overflowed"`
+
+	// Entries 是按源和原因聚合的统计
+	Entries []CollectorEntry `json:"entries"`
+}
+
+type collectorKey struct {
+	origin string
+	reason Reason
+}
+
+// Collector 是一个接收Reporter发送的违规报告的http.Handler。
+// POST请求提交报告，GET请求以JSON格式返回聚合的统计
+type Collector struct {
+	// MaxBodySize 是单个请求体的最大字节数，默认为1MB
+	MaxBodySize int64
+
+	// MaxEntries 是按源和原因聚合的最大条目数，超出后新的源和原因不再被统计，默认为10000
+	MaxEntries int
+
+	accepted   uint64
+	rejected   uint64
+	overflowed uint64
+
+	mu      sync.Mutex
+	entries map[collectorKey]*CollectorEntry
+}
+
+// NewCollector 创建一个新的报告收集器
+func NewCollector() *Collector {
+	return &Collector{
+		MaxBodySize: 1 << 20,
+		MaxEntries:  10000,
+		entries:     map[collectorKey]*CollectorEntry{},
+	}
+}
+
+// ServeHTTP 实现http.Handler接口
+func (col *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPost:
+		col.receive(w, r)
+	case http.MethodGet, http.MethodHead:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(col.Summary())
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
+}
+
+// Summary 返回当前聚合的统计，按源和原因排序
+func (col *Collector) Summary() CollectorSummary {
+	s := CollectorSummary{
+		Accepted:   atomic.LoadUint64(&col.accepted),
+		Rejected:   atomic.LoadUint64(&col.rejected),
+		Overflowed: atomic.LoadUint64(&col.overflowed),
+		Entries:    []CollectorEntry{},
+	}
+
+	col.mu.Lock()
+	for _, e := range col.entries {
+		s.Entries = append(s.Entries, *e)
+	}
+	col.mu.Unlock()
+
+	sort.Slice(s.Entries, func(i, j int) bool {
+		if s.Entries[i].Origin != s.Entries[j].Origin {
+			return s.Entries[i].Origin < s.Entries[j].Origin
+		}
+		return s.Entries[i].Reason < s.Entries[j].Reason
+	})
+	return s
+}
+
+func (col *Collector) receive(w http.ResponseWriter, r *http.Request) {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mt != "application/reports+json" && mt != "application/json" {
+		http.Error(w, http.StatusText(http.StatusUnsupportedMediaType), http.StatusUnsupportedMediaType)
+		return
+	}
+
+	maxBody := col.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = 1 << 20
+	}
+	var reports []Report
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody)).Decode(&reports); err != nil {
+		status := http.StatusBadRequest
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+
+	now := time.Now()
+	for _, rep := range reports {
+		if !validReport(rep) {
+			atomic.AddUint64(&col.rejected, 1)
+			continue
+		}
+		atomic.AddUint64(&col.accepted, 1)
+		col.add(rep, now)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (col *Collector) add(rep Report, now time.Time) {
+	seen := now
+	if rep.Body.Timestamp > 0 {
+		seen = time.Unix(0, rep.Body.Timestamp*int64(time.Millisecond))
+	}
+	key := collectorKey{rep.Body.Origin, rep.Body.Reason}
+
+	maxEntries := col.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = 10000
+	}
+
+	col.mu.Lock()
+	defer col.mu.Unlock()
+	if col.entries == nil {
+		col.entries = map[collectorKey]*CollectorEntry{}
+	}
+	e, ok := col.entries[key]
+	if !ok {
+		// 源和原因由客户端提交，限制条目数以避免内存无限增长
+		if len(col.entries) >= maxEntries {
+			atomic.AddUint64(&col.overflowed, 1)
+			return
+		}
+		e = &CollectorEntry{
+			Origin:    rep.Body.Origin,
+			Reason:    rep.Body.Reason,
+			FirstSeen: seen,
+		}
+		col.entries[key] = e
+	}
+	e.Count++
+	e.LastURL = rep.URL
+	if seen.Before(e.FirstSeen) {
+		e.FirstSeen = seen
+	}
+	if seen.After(e.LastSeen) {
+		e.LastSeen = seen
+	}
+}
+
+func validReport(rep Report) bool {
+	if rep.Type != ReportType || rep.Body.Reason == ReasonNone {
+		return false
+	}
+	// 资源隔离拒绝的no-cors请求可能既没有Origin也没有Referer
+	if rep.Body.Origin == "" && rep.Body.Reason != ReasonCrossSiteBlocked {
+		return false
+	}
+	switch rep.Body.Disposition {
+	case "enforce", "report":
+	default:
+		return false
+	}
+	return rep.Body.Timestamp >= 0
+}
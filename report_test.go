@@ -0,0 +1,144 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestReporterCollector(t *testing.T) {
+	col := NewCollector()
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	rp := NewReporter(ReporterOptions{
+		Endpoint:      srv.URL,
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	})
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		OnViolation:    rp.Report,
+	})
+
+	for _, origin := range []string{"http://barbaz.com", "http://barbaz.com", "http://foobaz.com", "http://foobar.com"} {
+		req, _ := http.NewRequest("GET", "http://example.com/foo?secret=1", nil)
+		req.Header.Set("Origin", origin)
+		s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	}
+	assert.Nil(t, rp.Close())
+	assert.DeepEqual(t, rp.Dropped(), uint64(0))
+
+	sum := col.Summary()
+	assert.DeepEqual(t, sum.Accepted, uint64(3))
+	assert.DeepEqual(t, sum.Rejected, uint64(0))
+	assert.Len(t, sum.Entries, 2)
+	assert.DeepEqual(t, sum.Entries[0].Origin, "http://barbaz.com")
+	assert.DeepEqual(t, sum.Entries[0].Reason, ReasonOriginNotAllowed)
+	assert.DeepEqual(t, sum.Entries[0].Count, uint64(2))
+	assert.DeepEqual(t, sum.Entries[0].LastURL, "http://example.com/foo")
+	assert.DeepEqual(t, sum.Entries[1].Origin, "http://foobaz.com")
+}
+
+func TestReporterBoundedBuffer(t *testing.T) {
+	rp := NewReporter(ReporterOptions{
+		Endpoint:      "http://127.0.0.1:0/",
+		BatchSize:     10,
+		MaxBuffered:   2,
+		FlushInterval: time.Hour,
+	})
+	v := Violation{Decision: Decision{Origin: "http://foobar.com", Reason: ReasonOriginNotAllowed}, Time: time.Now()}
+	for i := 0; i < 3; i++ {
+		rp.Report(v)
+	}
+	assert.DeepEqual(t, rp.Dropped(), uint64(1))
+
+	// 发送失败的报告同样计入丢弃数
+	assert.NotNil(t, rp.Close())
+	assert.DeepEqual(t, rp.Dropped(), uint64(3))
+}
+
+func TestReporterTimeout(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	rp := NewReporter(ReporterOptions{
+		Endpoint:      srv.URL,
+		FlushInterval: 50 * time.Millisecond,
+	})
+	rp.Report(Violation{Decision: Decision{Origin: "http://foobar.com", Reason: ReasonOriginNotAllowed}, Time: time.Now()})
+
+	// 报告地址没有响应时Close仍然会在超时之后返回
+	closed := make(chan struct{})
+	go func() {
+		rp.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+		assert.DeepEqual(t, rp.Dropped(), uint64(1))
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close blocked on an unresponsive endpoint")
+	}
+}
+
+func TestCollectorValidation(t *testing.T) {
+	col := NewCollector()
+
+	post := func(contentType, body string) int {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		res := httptest.NewRecorder()
+		col.ServeHTTP(res, req)
+		return res.Code
+	}
+
+	assert.DeepEqual(t, post("text/plain", "[]"), http.StatusUnsupportedMediaType)
+	assert.DeepEqual(t, post("application/reports+json", "{"), http.StatusBadRequest)
+	assert.DeepEqual(t, post("application/reports+json", `[
+		{"type":"cors-violation","url":"http://example.com/","body":{"origin":"http://foobar.com","method":"GET","reason":"origin_not_allowed","disposition":"report","timestamp":1}},
+		{"type":"csp-violation","url":"http://example.com/","body":{"origin":"http://foobar.com","reason":"origin_not_allowed","disposition":"report"}},
+		{"type":"cors-violation","url":"http://example.com/","body":{"origin":"","reason":"origin_not_allowed","disposition":"report"}},
+		{"type":"cors-violation","url":"http://example.com/","body":{"origin":"","reason":"cross_site_blocked","disposition":"enforce"}}
+	]`), http.StatusNoContent)
+
+	col.MaxBodySize = 8
+	assert.DeepEqual(t, post("application/json", `[{"type":"cors-violation"}]`), http.StatusRequestEntityTooLarge)
+
+	sum := col.Summary()
+	assert.DeepEqual(t, sum.Accepted, uint64(2))
+	assert.DeepEqual(t, sum.Rejected, uint64(2))
+	assert.DeepEqual(t, sum.Entries[0].Reason, ReasonCrossSiteBlocked)
+	assert.DeepEqual(t, sum.Entries[1].FirstSeen, time.Unix(0, int64(time.Millisecond)))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	res := httptest.NewRecorder()
+	col.ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Content-Type"), "application/json")
+	assert.StringContains(t, res.Body.String(), `"accepted":2`)
+
+	req, _ = http.NewRequest("DELETE", "/", nil)
+	res = httptest.NewRecorder()
+	col.ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Code, http.StatusMethodNotAllowed)
+}
+
+func TestCollectorZeroValueAndLimit(t *testing.T) {
+	col := &Collector{MaxEntries: 2}
+	for _, origin := range []string{"http://a.com", "http://b.com", "http://c.com", "http://a.com"} {
+		col.add(Report{Type: ReportType, Body: ReportBody{Origin: origin, Reason: ReasonOriginNotAllowed}}, time.Now())
+	}
+
+	sum := col.Summary()
+	assert.Len(t, sum.Entries, 2)
+	assert.DeepEqual(t, sum.Entries[0].Count, uint64(2))
+	assert.DeepEqual(t, sum.Overflowed, uint64(1))
+}
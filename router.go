@@ -0,0 +1,91 @@
+package cors
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// MethodLister 由路由实现，返回请求路径上实际注册的方法。
+// 设置Options.MethodLister后，预检请求只允许路由上注册的方法，并在Access-Control-Allow-Methods中列出这些方法
+type MethodLister interface {
+	AllowedMethods(r *http.Request) []string
+}
+
+// MethodListerFunc 是一个适配器，允许将普通函数用作MethodLister
+type MethodListerFunc func(r *http.Request) []string
+
+// AllowedMethods 调用f(r)
+func (f MethodListerFunc) AllowedMethods(r *http.Request) []string {
+	return f(r)
+}
+
+var defaultRouteMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// ServeMuxMethods 返回一个基于Go 1.22 http.ServeMux方法模式的MethodLister。
+// 它使用candidates中的每个方法探测请求路径是否有匹配的模式，candidates为空时使用GET, HEAD, POST, PUT, PATCH, DELETE。
+// ServeMux会重定向的路径（例如缺少末尾斜杠或者未规范化的路径）不算作注册的路由
+func ServeMuxMethods(mux *http.ServeMux, candidates ...string) MethodLister {
+	if len(candidates) == 0 {
+		candidates = defaultRouteMethods
+	}
+	return MethodListerFunc(func(r *http.Request) []string {
+		var methods []string
+		probe := r.Clone(r.Context())
+		for _, m := range candidates {
+			probe.Method = m
+			if _, pattern := mux.Handler(probe); pattern != "" && !muxRedirects(r.URL.Path, pattern) {
+				methods = append(methods, m)
+			}
+		}
+		return methods
+	})
+}
+
+// muxRedirects 判断ServeMux对请求路径返回的pattern是否只是重定向的目标，而不是直接处理该路径的路由
+func muxRedirects(reqPath, pattern string) bool {
+	if reqPath == "" {
+		reqPath = "/"
+	}
+	clean := path.Clean(reqPath)
+	if strings.HasSuffix(reqPath, "/") && clean != "/" {
+		clean += "/"
+	}
+	if clean != reqPath {
+		return true
+	}
+
+	// 去掉pattern中的方法和主机部分
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		pattern = strings.TrimLeft(pattern[i+1:], " \t")
+	}
+	if i := strings.IndexByte(pattern, '/'); i > 0 {
+		pattern = pattern[i:]
+	}
+	pattern = strings.TrimSuffix(pattern, "{$}")
+	return strings.HasSuffix(pattern, "/") && !strings.HasSuffix(reqPath, "/") &&
+		strings.Count(pattern, "/") == strings.Count(reqPath, "/")+1
+}
+
+// routeMethods 返回路由为请求路径注册的方法，如果同时配置了AllowedMethods则只保留其中允许的方法
+func (c *Cors) routeMethods(r *http.Request) []string {
+	methods := []string{}
+	for _, m := range c.methodLister.AllowedMethods(r) {
+		m = c.normalizeMethod(m)
+		if m == http.MethodOptions {
+			continue
+		}
+		if c.methodsConfigured && !c.isMethodAllowed(m) {
+			continue
+		}
+		methods = append(methods, m)
+	}
+	return methods
+}
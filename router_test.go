@@ -0,0 +1,154 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func newRouteMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /items/{id}", testHandler)
+	mux.Handle("PUT /items/{id}", testHandler)
+	mux.Handle("DELETE /items/{id}", testHandler)
+	mux.Handle("POST /items", testHandler)
+	mux.Handle("GET /dir/", testHandler)
+	return mux
+}
+
+func TestServeMuxMethods(t *testing.T) {
+	l := ServeMuxMethods(newRouteMux())
+
+	req, _ := http.NewRequest("OPTIONS", "http://example.com/items/1", nil)
+	assert.DeepEqual(t, l.AllowedMethods(req), []string{"GET", "HEAD", "PUT", "DELETE"})
+	assert.DeepEqual(t, req.Method, "OPTIONS")
+
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/items", nil)
+	assert.DeepEqual(t, l.AllowedMethods(req), []string{"POST"})
+
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/nope", nil)
+	assert.Empty(t, l.AllowedMethods(req))
+
+	// ServeMux只会把这些路径重定向到已注册的路由
+	for _, p := range []string{"/dir", "/items//1", "/dir/../items/1"} {
+		req, _ = http.NewRequest("OPTIONS", "http://example.com"+p, nil)
+		assert.Empty(t, l.AllowedMethods(req))
+	}
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/dir/file", nil)
+	assert.DeepEqual(t, l.AllowedMethods(req), []string{"GET", "HEAD"})
+
+	l = ServeMuxMethods(newRouteMux(), "PUT", "PATCH")
+	req, _ = http.NewRequest("OPTIONS", "http://example.com/items/1", nil)
+	assert.DeepEqual(t, l.AllowedMethods(req), []string{"PUT"})
+}
+
+func TestMethodLister(t *testing.T) {
+	mux := newRouteMux()
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		MethodLister:   ServeMuxMethods(mux),
+	})
+
+	cases := []struct {
+		name       string
+		path       string
+		method     string
+		resHeaders map[string]string
+	}{
+		{
+			"RegisteredMethod",
+			"/items/1",
+			"PUT",
+			map[string]string{
+				"Vary":                         "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+				"Access-Control-Allow-Origin":  "http://foobar.com",
+				"Access-Control-Allow-Methods": "GET, HEAD, PUT, DELETE",
+			},
+		},
+		{
+			"UnregisteredMethod",
+			"/items/1",
+			"PATCH",
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+		{
+			"NoRoute",
+			"/nope",
+			"GET",
+			map[string]string{
+				"Vary": "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+			},
+		},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("OPTIONS", "http://example.com"+tc.path, nil)
+			req.Header.Set("Origin", "http://foobar.com")
+			req.Header.Set("Access-Control-Request-Method", tc.method)
+			res := httptest.NewRecorder()
+			s.Handler(mux).ServeHTTP(res, req)
+			assertHeaders(t, res.Header(), tc.resHeaders)
+		})
+	}
+
+	// 实际请求同样使用路由上注册的方法
+	req, _ := http.NewRequest("DELETE", "http://example.com/items/1", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	s.Handler(mux).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "http://foobar.com")
+}
+
+func TestMethodListerWithAllowedMethods(t *testing.T) {
+	s := New(Options{
+		AllowedMethods: []string{"GET", "PUT"},
+		MethodLister: MethodListerFunc(func(r *http.Request) []string {
+			return []string{"get", "put", "delete", "options"}
+		}),
+	})
+	d := s.Evaluate(newPreflight("http://foobar.com", "DELETE"))
+	assert.DeepEqual(t, d.Reason, ReasonMethodNotAllowed)
+	assert.DeepEqual(t, d.RouteMethods, []string{"GET", "PUT"})
+	assert.True(t, s.Evaluate(newPreflight("http://foobar.com", "PUT")).Allowed)
+}
+
+func TestMethodListerOnlyForAllowedOrigins(t *testing.T) {
+	probes := 0
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		MethodLister: MethodListerFunc(func(r *http.Request) []string {
+			probes++
+			return []string{"GET"}
+		}),
+	})
+	h := s.Handler(testHandler)
+
+	// 没有Origin的请求和源不被允许的请求不会查询路由
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	req.Header.Set("Origin", "http://barbaz.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	h.ServeHTTP(httptest.NewRecorder(), newPreflight("http://barbaz.com", "GET"))
+	assert.DeepEqual(t, probes, 0)
+
+	req.Header.Set("Origin", "http://foobar.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.DeepEqual(t, probes, 1)
+}
+
+func TestMuxRedirects(t *testing.T) {
+	assert.True(t, muxRedirects("/dir", "GET /dir/"))
+	assert.True(t, muxRedirects("/items/1", "example.com/items/{id}/"))
+	assert.True(t, muxRedirects("/dir", "/dir/{$}"))
+	assert.True(t, muxRedirects("/a//b", "POST /a/b"))
+	assert.False(t, muxRedirects("/dir/", "GET /dir/"))
+	assert.False(t, muxRedirects("/dir/x", "GET /dir/"))
+	assert.False(t, muxRedirects("/foo", "/"))
+	assert.False(t, muxRedirects("", "/"))
+}
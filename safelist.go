@@ -0,0 +1,149 @@
+package cors
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// 按照Fetch规范，单个安全列表头部的值不超过128字节，所有安全列表头部的值合计不超过1024字节
+const (
+	maxSafelistedValue = 128
+	maxSafelistedTotal = 1024
+)
+
+// IsSafelistedMethod 判断method是否是CORS安全列表方法，即GET、HEAD或POST
+func IsSafelistedMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+		return true
+	}
+	return false
+}
+
+// IsSafelistedHeader 判断名称为name、值为value的头部是否是Fetch规范定义的CORS安全列表请求头部。
+// Accept、Accept-Language和Content-Language的值为空时也是安全的；
+// Content-Type只有在值为application/x-www-form-urlencoded、multipart/form-data或text/plain时才是安全的
+func IsSafelistedHeader(name, value string) bool {
+	if len(value) > maxSafelistedValue {
+		return false
+	}
+	switch http.CanonicalHeaderKey(name) {
+	case "Accept":
+		return !hasUnsafeHeaderByte(value)
+	case "Accept-Language", "Content-Language":
+		for i := 0; i < len(value); i++ {
+			if !isLanguageByte(value[i]) {
+				return false
+			}
+		}
+		return true
+	case "Content-Type":
+		if hasUnsafeHeaderByte(value) {
+			return false
+		}
+		mediaType, _, err := mime.ParseMediaType(value)
+		if err != nil {
+			return false
+		}
+		switch mediaType {
+		case "application/x-www-form-urlencoded", "multipart/form-data", "text/plain":
+			return true
+		}
+		return false
+	case "Range":
+		return isSimpleRange(value)
+	}
+	return false
+}
+
+// IsSimpleRequest 判断请求是否是不需要预检的简单请求，即方法和所有由脚本设置的头部都在CORS安全列表中。
+// 服务端无法区分浏览器自动添加的头部，因此禁止脚本设置的头部（如Host、Cookie、Sec-*）以及User-Agent将被忽略
+func IsSimpleRequest(r *http.Request) bool {
+	if !IsSafelistedMethod(r.Method) {
+		return false
+	}
+	total := 0
+	for name, values := range r.Header {
+		if isForbiddenHeader(name) {
+			continue
+		}
+		value := strings.Join(values, ", ")
+		if !IsSafelistedHeader(name, value) {
+			return false
+		}
+		total += len(value)
+	}
+	return total <= maxSafelistedTotal
+}
+
+// isSafelistedResponseHeader 判断name是否是不需要暴露就可以被脚本读取的CORS安全列表响应头部
+func isSafelistedResponseHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Cache-Control", "Content-Language", "Content-Length", "Content-Type",
+		"Expires", "Last-Modified", "Pragma":
+		return true
+	}
+	return false
+}
+
+// hasUnsafeHeaderByte 判断value是否包含Fetch规范定义的CORS不安全请求头部字节
+func hasUnsafeHeaderByte(value string) bool {
+	for i := 0; i < len(value); i++ {
+		b := value[i]
+		if (b < 0x20 && b != '\t') || b == 0x7f || strings.IndexByte("\"():<>?@[\\]{}", b) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func isLanguageByte(b byte) bool {
+	switch {
+	case b >= '0' && b <= '9', b >= 'A' && b <= 'Z', b >= 'a' && b <= 'z':
+		return true
+	}
+	return strings.IndexByte(" *,-.;=", b) >= 0
+}
+
+// isSimpleRange 判断value是否是"bytes=N-"或者"bytes=N-M"形式的简单范围
+func isSimpleRange(value string) bool {
+	rest := strings.TrimPrefix(value, "bytes=")
+	if rest == value {
+		return false
+	}
+	i := strings.IndexByte(rest, '-')
+	if i <= 0 || !isDigits(rest[:i]) {
+		return false
+	}
+	return rest[i+1:] == "" || isDigits(rest[i+1:])
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isForbiddenHeader 判断name是否是脚本不能设置的头部
+func isForbiddenHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if strings.HasPrefix(name, "Proxy-") || strings.HasPrefix(name, "Sec-") {
+		return true
+	}
+	switch name {
+	case "Accept-Charset", "Accept-Encoding", "Access-Control-Request-Headers",
+		"Access-Control-Request-Method", "Access-Control-Request-Private-Network",
+		"Connection", "Content-Length", "Cookie", "Cookie2", "Date", "Dnt", "Expect",
+		"Host", "Keep-Alive", "Origin", "Referer", "Set-Cookie", "Te", "Trailer",
+		"Transfer-Encoding", "Upgrade", "User-Agent", "Via":
+		return true
+	}
+	return false
+}
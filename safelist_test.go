@@ -0,0 +1,84 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestIsSafelistedHeader(t *testing.T) {
+	cases := []struct {
+		name  string
+		value string
+		safe  bool
+	}{
+		{"Accept", "", true},
+		{"accept", "application/json, text/*;q=0.8", true},
+		{"Accept", "text/html(", false},
+		{"Accept", strings.Repeat("a", 129), false},
+		{"Accept-Language", "en-US,en;q=0.9", true},
+		{"Accept-Language", "en_US", false},
+		{"Content-Language", "", true},
+		{"Content-Type", "", false},
+		{"Content-Type", "text/plain; charset=utf-8", true},
+		{"Content-Type", "Multipart/Form-Data; boundary=x", true},
+		{"Content-Type", "application/json", false},
+		{"Content-Type", "text/plain; a=\"b\"", false},
+		{"Range", "bytes=0-", true},
+		{"Range", "bytes=10-20", true},
+		{"Range", "bytes=-20", false},
+		{"Range", "bytes=0-1,2-3", false},
+		{"Range", "", false},
+		{"X-Requested-With", "XMLHttpRequest", false},
+	}
+	for _, tc := range cases {
+		assert.DeepEqual(t, IsSafelistedHeader(tc.name, tc.value), tc.safe)
+	}
+}
+
+func TestIsSafelistedMethod(t *testing.T) {
+	assert.True(t, IsSafelistedMethod("GET"))
+	assert.True(t, IsSafelistedMethod("HEAD"))
+	assert.True(t, IsSafelistedMethod("POST"))
+	assert.False(t, IsSafelistedMethod("PUT"))
+	assert.False(t, IsSafelistedMethod("OPTIONS"))
+}
+
+func TestIsSimpleRequest(t *testing.T) {
+	req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	req.Header.Set("User-Agent", "test")
+	req.Header.Set("Sec-Fetch-Mode", "cors")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.True(t, IsSimpleRequest(req))
+
+	req.Header.Set("Content-Type", "application/json")
+	assert.False(t, IsSimpleRequest(req))
+
+	req.Header.Del("Content-Type")
+	req.Header.Set("X-Requested-With", "XMLHttpRequest")
+	assert.False(t, IsSimpleRequest(req))
+
+	req.Header.Del("X-Requested-With")
+	req.Method = "PUT"
+	assert.False(t, IsSimpleRequest(req))
+
+	// 同名的多个头部合并后计算长度
+	req.Method = "GET"
+	req.Header.Add("Accept", strings.Repeat("a", 100))
+	assert.True(t, IsSimpleRequest(req))
+	req.Header.Add("Accept", strings.Repeat("a", 100))
+	assert.False(t, IsSimpleRequest(req))
+}
+
+func TestSafelistedHeadersAlwaysAllowed(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		AllowedHeaders: []string{"X-Header-1"},
+	})
+	assert.True(t, s.areHeadersAllowed([]string{"Accept", "Accept-Language", "Content-Language", "X-Header-1"}))
+	assert.False(t, s.areHeadersAllowed([]string{"Content-Type"}))
+	assert.False(t, s.areHeadersAllowed([]string{"Range"}))
+}
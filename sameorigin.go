@@ -0,0 +1,137 @@
+package cors
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parseTrustedProxies 将IP或CIDR列表转换为网络列表，无效的条目将被忽略
+func (c *Cors) parseTrustedProxies(proxies []string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, p := range proxies {
+		p = strings.TrimSpace(p)
+		if !strings.Contains(p, "/") {
+			ip := net.ParseIP(p)
+			if ip == nil {
+				c.logf("New: ignoring invalid trusted proxy '%s'", p)
+				continue
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(p)
+		if err != nil {
+			c.logf("New: ignoring invalid trusted proxy '%s': %v", p, err)
+			continue
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// isTrustedProxy 判断请求是否来自受信任的反向代理
+func (c *Cors) isTrustedProxy(r *http.Request) bool {
+	if len(c.trustedProxies) == 0 {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, n := range c.trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestOrigin 返回请求自身的源，即scheme://host。
+// 只有来自受信任的反向代理的请求才会使用Forwarded或者X-Forwarded-Proto/X-Forwarded-Host头部，
+// 并且只使用最后一个元素，即受信任的反向代理自己添加的元素，之前的元素可能由客户端伪造
+func (c *Cors) requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	host := r.Host
+
+	if c.isTrustedProxy(r) {
+		if fwd := headerList(r, "Forwarded"); fwd != "" {
+			proto, fhost := parseForwarded(fwd)
+			if proto != "" {
+				scheme = proto
+			}
+			if fhost != "" {
+				host = fhost
+			}
+		} else {
+			if proto := lastValue(headerList(r, "X-Forwarded-Proto")); proto != "" {
+				scheme = proto
+			}
+			if fhost := lastValue(headerList(r, "X-Forwarded-Host")); fhost != "" {
+				host = fhost
+			}
+		}
+	}
+
+	scheme = strings.ToLower(scheme)
+	host = strings.ToLower(host)
+	// 去掉默认端口，浏览器发送的Origin中不会包含默认端口
+	if scheme == "http" {
+		host = strings.TrimSuffix(host, ":80")
+	} else if scheme == "https" {
+		host = strings.TrimSuffix(host, ":443")
+	}
+	return scheme + "://" + host
+}
+
+// isSameOrigin 判断origin是否与请求自身的源相同
+func (c *Cors) isSameOrigin(r *http.Request, origin string) bool {
+	return origin != "" && strings.EqualFold(origin, c.requestOrigin(r))
+}
+
+// skipSameOriginRequest 判断是否应当跳过一个同源请求的CORS处理
+func (c *Cors) skipSameOriginRequest(r *http.Request) bool {
+	return c.skipSameOrigin && c.isSameOrigin(r, r.Header.Get("Origin"))
+}
+
+// parseForwarded 返回RFC 7239 Forwarded头部中最后一个元素的proto和host参数
+func parseForwarded(value string) (proto, host string) {
+	for _, pair := range strings.Split(lastValue(value), ";") {
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(pair[:i]))
+		v := strings.Trim(strings.TrimSpace(pair[i+1:]), `"`)
+		switch name {
+		case "proto":
+			proto = v
+		case "host":
+			host = v
+		}
+	}
+	return proto, host
+}
+
+// headerList 将同名的多个头部合并为一个逗号分隔的列表
+func headerList(r *http.Request, name string) string {
+	return strings.Join(r.Header.Values(name), ", ")
+}
+
+func lastValue(list string) string {
+	if i := strings.LastIndexByte(list, ','); i >= 0 {
+		list = list[i+1:]
+	}
+	return strings.TrimSpace(list)
+}
@@ -0,0 +1,101 @@
+package cors
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestRequestOrigin(t *testing.T) {
+	s := New(Options{TrustedProxies: []string{"10.0.0.0/8", "192.0.2.1", "bogus"}})
+
+	cases := []struct {
+		name       string
+		remoteAddr string
+		tls        bool
+		reqHeaders map[string]string
+		origin     string
+	}{
+		{"Plain", "203.0.113.1:1234", false, nil, "http://example.com"},
+		{"TLS", "203.0.113.1:1234", true, nil, "https://example.com"},
+		{"UntrustedForwarded", "203.0.113.1:1234", false, map[string]string{
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "api.example.com",
+		}, "http://example.com"},
+		{"TrustedXForwarded", "10.1.2.3:1234", false, map[string]string{
+			"X-Forwarded-Proto": "https",
+			"X-Forwarded-Host":  "api.example.com",
+		}, "https://api.example.com"},
+		{"TrustedForwarded", "192.0.2.1:1234", false, map[string]string{
+			"Forwarded":        `for=198.51.100.1;proto=https;host="API.example.com:443"`,
+			"X-Forwarded-Host": "ignored.example.com",
+		}, "https://api.example.com"},
+		// 受信任的反向代理追加元素时，之前的元素由客户端控制
+		{"MultiHopXForwarded", "10.1.2.3:1234", false, map[string]string{
+			"X-Forwarded-Proto": "http, https",
+			"X-Forwarded-Host":  "evil.com, api.example.com",
+		}, "https://api.example.com"},
+		{"MultiHopForwarded", "192.0.2.1:1234", false, map[string]string{
+			"Forwarded": `for=198.51.100.1;host=evil.com, for=10.0.0.1;proto=https;host="API.example.com:443"`,
+		}, "https://api.example.com"},
+		{"MultiHopForwardedWithoutHost", "192.0.2.1:1234", false, map[string]string{
+			"Forwarded": `for=198.51.100.1;proto=https;host=evil.com, for=10.0.0.1`,
+		}, "http://example.com"},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.tls {
+				req.TLS = &tls.ConnectionState{}
+			}
+			for name, value := range tc.reqHeaders {
+				req.Header.Add(name, value)
+			}
+			assert.DeepEqual(t, s.requestOrigin(req), tc.origin)
+		})
+	}
+
+	// 多个同名头部同样只使用最后一个元素
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+	req.Header.Add("X-Forwarded-Host", "evil.com")
+	req.Header.Add("X-Forwarded-Host", "api.example.com")
+	assert.DeepEqual(t, s.requestOrigin(req), "http://api.example.com")
+}
+
+func TestSkipSameOrigin(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		SkipSameOrigin: true,
+		TrustedProxies: []string{"10.0.0.1"},
+	})
+
+	req, _ := http.NewRequest("POST", "http://example.com/foo", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Origin", "https://example.com")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{"Vary": "Origin"})
+	assert.DeepEqual(t, res.Body.String(), "hello")
+
+	// 来自不受信任地址的请求不会使用X-Forwarded-Proto，因此不是同源请求
+	req.RemoteAddr = "10.0.0.2:1234"
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{"Vary": "Origin"})
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	s := New(Options{})
+	nets := s.parseTrustedProxies([]string{"10.0.0.0/8", "::1", "127.0.0.1", "nope", "10.0.0.0/99"})
+	assert.Len(t, nets, 3)
+	assert.DeepEqual(t, nets[2].String(), "127.0.0.1/32")
+}
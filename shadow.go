@@ -0,0 +1,134 @@
+package cors
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Disagreement 描述主策略与候选策略对同一个请求做出的不同判定
+type Disagreement struct {
+	// Primary 是主策略的判定结果，也是实际生效的判定结果
+	Primary Decision
+
+	// Candidate 是候选策略的判定结果
+	Candidate Decision
+
+	// Method 是请求的方法
+	Method string
+
+	// URL 是请求的URL
+	URL string
+
+	// Host 是请求的Host
+	Host string
+
+	// RemoteAddr 是请求的客户端地址
+	RemoteAddr string
+
+	// Header 是请求头部的副本
+	Header http.Header
+
+	// Time 是判定发生的时间
+	Time time.Time
+}
+
+// ShadowStats 是影子策略比较的计数
+type ShadowStats struct {
+	// Evaluated 是比较过的跨域请求数
+	Evaluated uint64
+
+	// PrimaryAllowedCandidateDenied 是主策略允许而候选策略拒绝的请求数
+	PrimaryAllowedCandidateDenied uint64
+
+	// PrimaryDeniedCandidateAllowed 是主策略拒绝而候选策略允许的请求数
+	PrimaryDeniedCandidateAllowed uint64
+}
+
+// Shadow 使用主策略处理请求，同时使用候选策略评估每一个请求，并报告两者的分歧
+type Shadow struct {
+	primary   *Cors
+	candidate *Cors
+	onDiff    func(d Disagreement)
+
+	evaluated     uint64
+	allowedDenied uint64
+	deniedAllowed uint64
+}
+
+// NewShadow 创建一个影子策略比较器。
+// primary 用于实际响应请求，candidate 只参与评估，onDiff 在两者判定不同时调用，可以为nil
+func NewShadow(primary, candidate *Cors, onDiff func(d Disagreement)) *Shadow {
+	return &Shadow{
+		primary:   primary,
+		candidate: candidate,
+		onDiff:    onDiff,
+	}
+}
+
+// Handler 使用主策略为请求应用CORS规范，并与候选策略进行比较
+func (s *Shadow) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.serve(w, r, h, "Handler")
+	})
+}
+
+// HandlerFunc 提供兼容的处理器函数
+func (s *Shadow) HandlerFunc(w http.ResponseWriter, r *http.Request) {
+	s.serve(w, r, nil, "HandlerFunc")
+}
+
+// ServeHTTP 提供兼容性接口
+func (s *Shadow) ServeHTTP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	s.serve(w, r, next, "ServeHTTP")
+}
+
+// serve 使用主策略处理请求，并复用主策略的判定结果与候选策略比较。
+// 主策略没有评估CORS策略的请求（同源请求、资源隔离、静态响应）不参与比较
+func (s *Shadow) serve(w http.ResponseWriter, r *http.Request, next http.Handler, caller string) {
+	if pd, ok := s.primary.handle(w, r, next, caller); ok {
+		s.compare(r, pd)
+	}
+}
+
+// Stats 返回当前的比较计数
+func (s *Shadow) Stats() ShadowStats {
+	return ShadowStats{
+		Evaluated:                     atomic.LoadUint64(&s.evaluated),
+		PrimaryAllowedCandidateDenied: atomic.LoadUint64(&s.allowedDenied),
+		PrimaryDeniedCandidateAllowed: atomic.LoadUint64(&s.deniedAllowed),
+	}
+}
+
+func (s *Shadow) compare(r *http.Request, pd Decision) {
+	// 没有Origin的请求以及普通的OPTIONS请求不是跨域请求，两个策略都不会处理
+	if pd.Origin == "" || pd.Reason == ReasonOptionsRequest {
+		return
+	}
+	cd := s.candidate.Evaluate(r)
+	atomic.AddUint64(&s.evaluated, 1)
+	if pd.Allowed == cd.Allowed {
+		return
+	}
+
+	if pd.Allowed {
+		atomic.AddUint64(&s.allowedDenied, 1)
+	} else {
+		atomic.AddUint64(&s.deniedAllowed, 1)
+	}
+	s.primary.logf("Shadow: primary allowed=%v candidate allowed=%v (%s) for origin '%s'",
+		pd.Allowed, cd.Allowed, cd.Reason, pd.Origin)
+
+	if s.onDiff != nil {
+		s.onDiff(Disagreement{
+			Primary:    pd,
+			Candidate:  cd,
+			Method:     r.Method,
+			URL:        r.URL.String(),
+			Host:       r.Host,
+			RemoteAddr: r.RemoteAddr,
+			Header:     r.Header.Clone(),
+			Time:       time.Now(),
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestShadow(t *testing.T) {
+	var diffs []Disagreement
+	primary := New(Options{
+		AllowedOrigins: []string{"http://foobar.com", "http://barbaz.com"},
+	})
+	candidate := New(Options{
+		AllowedOrigins: []string{"http://foobar.com", "http://foobaz.com"},
+	})
+	s := NewShadow(primary, candidate, func(d Disagreement) {
+		diffs = append(diffs, d)
+	})
+	h := s.Handler(testHandler)
+
+	for _, origin := range []string{"http://foobar.com", "http://barbaz.com", "http://foobaz.com", ""} {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	assert.DeepEqual(t, s.Stats(), ShadowStats{
+		Evaluated:                     3,
+		PrimaryAllowedCandidateDenied: 1,
+		PrimaryDeniedCandidateAllowed: 1,
+	})
+	assert.Len(t, diffs, 2)
+	assert.DeepEqual(t, diffs[0].Primary.Origin, "http://barbaz.com")
+	assert.True(t, diffs[0].Primary.Allowed)
+	assert.DeepEqual(t, diffs[0].Candidate.Reason, ReasonOriginNotAllowed)
+	assert.DeepEqual(t, diffs[0].URL, "http://example.com/foo")
+	assert.DeepEqual(t, diffs[0].Header.Get("Origin"), "http://barbaz.com")
+	assert.DeepEqual(t, diffs[1].Primary.Origin, "http://foobaz.com")
+	assert.True(t, diffs[1].Candidate.Allowed)
+}
+
+func TestShadowServesWithPrimary(t *testing.T) {
+	s := NewShadow(
+		New(Options{AllowedOrigins: []string{"http://foobar.com"}}),
+		New(Options{AllowedOrigins: []string{"http://barbaz.com"}}),
+		nil,
+	)
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+
+	res := httptest.NewRecorder()
+	s.ServeHTTP(res, req, testHandler)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "http://foobar.com")
+	assert.DeepEqual(t, res.Body.String(), "hello")
+
+	res = httptest.NewRecorder()
+	s.HandlerFunc(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "http://foobar.com")
+	assert.DeepEqual(t, s.Stats().PrimaryAllowedCandidateDenied, uint64(2))
+}
+
+func TestShadowSkipsShortCircuited(t *testing.T) {
+	probes := 0
+	primary := New(Options{
+		AllowedOrigins: []string{"http://foobar.com"},
+		SkipSameOrigin: true,
+		MethodLister: MethodListerFunc(func(r *http.Request) []string {
+			probes++
+			return []string{"GET"}
+		}),
+	})
+	s := NewShadow(primary, New(Options{AllowedOrigins: []string{"http://barbaz.com"}}), nil)
+	h := s.Handler(testHandler)
+
+	// 同源请求不经过CORS策略，不参与比较
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://example.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.DeepEqual(t, s.Stats(), ShadowStats{})
+
+	// 主策略的判定结果被复用，路由只被探测一次
+	req.Header.Set("Origin", "http://foobar.com")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.DeepEqual(t, probes, 1)
+	assert.DeepEqual(t, s.Stats().PrimaryAllowedCandidateDenied, uint64(1))
+}
+
+func TestShadowIgnoresDerivedOrigin(t *testing.T) {
+	primary := New(Options{
+		AllowedOrigins:  []string{"http://foobar.com"},
+		RefererFallback: true,
+	})
+	s := NewShadow(primary, New(Options{AllowedOrigins: []string{"http://barbaz.com"}}), nil)
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Referer", "http://foobar.com/page")
+	s.Handler(testHandler).ServeHTTP(httptest.NewRecorder(), req)
+	assert.DeepEqual(t, s.Stats(), ShadowStats{})
+}
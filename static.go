@@ -0,0 +1,138 @@
+package cors
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Validate 检查Options的配置是否互相兼容。
+// StaticResponse模式下响应不能依赖请求的源或者用户凭证，也不能回显请求的方法和头部
+func (o Options) Validate() error {
+	if err := o.validatePreflight(); err != nil {
+		return err
+	}
+	return o.validateStatic()
+}
+
+// dropIncompatible 忽略导致Validate失败的选项，返回忽略的原因。
+// PreflightContentLength与204状态码冲突时忽略PreflightContentLength，StaticResponse的配置不兼容时回退为普通模式
+func (o Options) dropIncompatible() (Options, []error) {
+	var ignored []error
+	if err := o.validatePreflight(); err != nil {
+		ignored = append(ignored, err)
+		o.PreflightContentLength = false
+	}
+	if err := o.validateStatic(); err != nil {
+		ignored = append(ignored, err)
+		o.StaticResponse = false
+	}
+	return o, ignored
+}
+
+func (o Options) validatePreflight() error {
+	if o.PreflightContentLength && o.PreflightStatus == http.StatusNoContent {
+		return errors.New("cors: PreflightContentLength cannot be used with a 204 PreflightStatus")
+	}
+	return nil
+}
+
+func (o Options) validateStatic() error {
+	if !o.StaticResponse {
+		return nil
+	}
+	switch {
+	case o.AllowCredentials:
+		return errors.New("cors: StaticResponse cannot be used with AllowCredentials")
+	case o.AllowOriginFunc != nil:
+		return errors.New("cors: StaticResponse cannot be used with AllowOriginFunc")
+	case len(o.AllowedOrigins) > 0 && !(len(o.AllowedOrigins) == 1 && o.AllowedOrigins[0] == "*"):
+		return errors.New("cors: StaticResponse requires all origins to be allowed")
+	case len(o.PrivateNetworkOrigins) > 0:
+		return errors.New("cors: StaticResponse cannot be used with PrivateNetworkOrigins")
+	case o.SkipSameOrigin, o.RefererFallback:
+		return errors.New("cors: StaticResponse cannot treat requests differently by origin")
+	case o.BlockDisallowed, o.ReportOnly:
+		return errors.New("cors: StaticResponse does not evaluate requests, BlockDisallowed and ReportOnly have no effect")
+	case o.ResourceIsolation != nil:
+		return errors.New("cors: StaticResponse cannot be used with ResourceIsolation")
+	case o.LazyHeaders:
+		return errors.New("cors: StaticResponse cannot be used with LazyHeaders")
+	case o.MethodLister != nil:
+		return errors.New("cors: StaticResponse cannot list route methods with MethodLister")
+	}
+
+	// 头部模式无法在响应中列出，"*"和禁止的头部同时存在时也无法使用通配符
+	all := false
+	for _, h := range o.AllowedHeaders {
+		if h == "*" {
+			all = true
+		} else if strings.Contains(h, "*") {
+			return errors.New("cors: StaticResponse cannot list header pattern " + strconv.Quote(h))
+		}
+	}
+	if all && len(o.DeniedHeaders) > 0 {
+		return errors.New("cors: StaticResponse cannot be used with AllowedHeaders \"*\" and DeniedHeaders")
+	}
+	return nil
+}
+
+// handleStatic 按照StaticResponse模式处理请求，响应不依赖于请求的源，因此不需要Vary
+func (c *Cors) handleStatic(w http.ResponseWriter, r *http.Request, next http.Handler) {
+	headers := w.Header()
+	headers.Set("Access-Control-Allow-Origin", "*")
+
+	if isPreflight(r) {
+		if c.allowMethodsValue != "" {
+			headers.Set("Access-Control-Allow-Methods", c.allowMethodsValue)
+		}
+		if c.allowHeadersValue != "" {
+			headers.Set("Access-Control-Allow-Headers", c.allowHeadersValue)
+		}
+		if c.privateNetwork {
+			headers.Set("Access-Control-Allow-Private-Network", "true")
+		}
+		if c.maxAge > 0 {
+			headers.Set("Access-Control-Max-Age", strconv.Itoa(c.maxAge))
+		}
+		c.logf("    Static preflight response headers: %v", headers)
+
+		if c.optionPassthrough {
+			if next != nil {
+				d := Decision{
+					Preflight: true,
+					Origin:    r.Header.Get("Origin"),
+					Method:    r.Header.Get("Access-Control-Request-Method"),
+					Allowed:   true,
+				}
+				next.ServeHTTP(w, withPreflight(r, c, d))
+			}
+		} else {
+			c.terminatePreflight(w, Decision{})
+		}
+		return
+	}
+
+	c.addCompanionHeaders(w)
+	if c.exposedHeadersAll {
+		headers.Set("Access-Control-Expose-Headers", "*")
+	} else if len(c.exposedHeaders) > 0 {
+		headers.Set("Access-Control-Expose-Headers", strings.Join(c.exposedHeaders, ", "))
+	}
+	if c.timingAllowOrigin {
+		headers.Set("Timing-Allow-Origin", "*")
+	}
+	c.logf("    Static response added headers: %v", headers)
+	if next != nil {
+		c.serveActual(w, r, next)
+	}
+}
+
+// staticCacheControl 返回StaticResponse模式下预检请求的响应默认的Cache-Control，允许共享缓存保存
+func staticCacheControl(maxAge int) string {
+	if maxAge > 0 {
+		return "public, max-age=" + strconv.Itoa(maxAge)
+	}
+	return "public"
+}
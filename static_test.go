@@ -0,0 +1,120 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestStaticResponse(t *testing.T) {
+	s := New(Options{
+		AllowedMethods:    []string{"GET", "PUT"},
+		AllowedHeaders:    []string{"X-Header-1"},
+		ExposedHeaders:    []string{"X-Static"},
+		MaxAge:            600,
+		TimingAllowOrigin: true,
+		StaticResponse:    true,
+	})
+
+	// 预检请求的响应与请求的源、方法和头部无关
+	for _, origin := range []string{"http://foobar.com", "http://barbaz.com"} {
+		req := newPreflight(origin, "DELETE")
+		req.Header.Set("Access-Control-Request-Headers", "X-Header-2")
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		assert.DeepEqual(t, res.Code, http.StatusOK)
+		assertHeaders(t, res.Header(), map[string]string{
+			"Access-Control-Allow-Origin":  "*",
+			"Access-Control-Allow-Methods": "GET, PUT",
+			"Access-Control-Allow-Headers": "Origin, X-Header-1, Accept, Accept-Language, Content-Language",
+			"Access-Control-Max-Age":       "600",
+		})
+		assert.DeepEqual(t, res.Header().Get("Cache-Control"), "public, max-age=600")
+	}
+
+	for _, origin := range []string{"http://foobar.com", ""} {
+		req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res := httptest.NewRecorder()
+		s.Handler(testHandler).ServeHTTP(res, req)
+		assertHeaders(t, res.Header(), map[string]string{
+			"Access-Control-Allow-Origin":   "*",
+			"Access-Control-Expose-Headers": "X-Static",
+		})
+		assert.DeepEqual(t, res.Header().Get("Timing-Allow-Origin"), "*")
+		assert.DeepEqual(t, res.Body.String(), "hello")
+	}
+}
+
+func TestStaticResponseWildcard(t *testing.T) {
+	s := New(Options{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"*"},
+		AllowedHeaders: []string{"*"},
+		StaticResponse: true,
+	})
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://foobar.com", "PUT"))
+	assertHeaders(t, res.Header(), map[string]string{
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "*",
+		"Access-Control-Allow-Headers": "*",
+	})
+	assert.DeepEqual(t, res.Header().Get("Cache-Control"), "public")
+}
+
+func TestOptionsValidate(t *testing.T) {
+	assert.Nil(t, Options{AllowCredentials: true}.Validate())
+	assert.Nil(t, Options{StaticResponse: true, AllowedOrigins: []string{"*"}}.Validate())
+
+	invalid := []Options{
+		{PreflightStatus: http.StatusNoContent, PreflightContentLength: true},
+		{StaticResponse: true, AllowCredentials: true},
+		{StaticResponse: true, AllowedOrigins: []string{"http://foobar.com"}},
+		{StaticResponse: true, AllowOriginFunc: func(string) bool { return true }},
+		{StaticResponse: true, PrivateNetworkOrigins: []string{"http://foobar.com"}},
+		{StaticResponse: true, SkipSameOrigin: true},
+		{StaticResponse: true, BlockDisallowed: true},
+		{StaticResponse: true, ResourceIsolation: &IsolationPolicy{}},
+		{StaticResponse: true, LazyHeaders: true},
+		{StaticResponse: true, MethodLister: ServeMuxMethods(http.NewServeMux())},
+		{StaticResponse: true, AllowedHeaders: []string{"X-Amz-*"}},
+		{StaticResponse: true, AllowedHeaders: []string{"*"}, DeniedHeaders: []string{"X-Debug"}},
+	}
+	for _, o := range invalid {
+		assert.NotNil(t, o.Validate())
+	}
+}
+
+func TestNewIgnoresIncompatibleOptions(t *testing.T) {
+	o := Options{
+		AllowedOrigins:         []string{"http://foobar.com"},
+		AllowCredentials:       true,
+		StaticResponse:         true,
+		PreflightStatus:        http.StatusNoContent,
+		PreflightContentLength: true,
+	}
+	_, err := NewChecked(o)
+	assert.NotNil(t, err)
+
+	// New不会panic，而是回退为普通模式并忽略PreflightContentLength
+	s := New(o)
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://foobar.com", "GET"))
+	assert.DeepEqual(t, res.Code, http.StatusNoContent)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                             "Origin, Access-Control-Request-Method, Access-Control-Request-Headers",
+		"Access-Control-Allow-Origin":      "http://foobar.com",
+		"Access-Control-Allow-Methods":     "GET",
+		"Access-Control-Allow-Credentials": "true",
+	})
+	assert.DeepEqual(t, res.Header().Get("Content-Length"), "")
+
+	s, err = NewChecked(Options{StaticResponse: true})
+	assert.Nil(t, err)
+	assert.NotNil(t, s)
+}
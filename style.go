@@ -0,0 +1,70 @@
+package cors
+
+import (
+	"strings"
+)
+
+// ResponseStyle 决定预检请求的响应如何填写Access-Control-Allow-Methods和Access-Control-Allow-Headers
+type ResponseStyle int
+
+const (
+	// ResponseEcho 回显预检请求中请求的方法或头部
+	ResponseEcho ResponseStyle = iota
+
+	// ResponseList 列出所有配置的方法或头部。
+	// 配置中包含"*"或者头部模式时无法完整列出，此时回退为ResponseEcho
+	ResponseList
+
+	// ResponseWildcard 在Fetch规范允许时使用字面量"*"，即配置为"*"并且不允许携带用户凭证时，
+	// 其他情况下回退为ResponseList
+	ResponseWildcard
+)
+
+// allowMethodsResponse 预先计算Access-Control-Allow-Methods的值，返回空字符串表示回显请求的方法
+func (c *Cors) allowMethodsResponse(style ResponseStyle) string {
+	if style == ResponseWildcard {
+		if c.allowedMethodsAll && !c.allowCredentials {
+			return "*"
+		}
+		style = ResponseList
+	}
+	if style == ResponseList && !c.allowedMethodsAll && len(c.allowedMethods) > 0 {
+		return strings.Join(c.allowedMethods, ", ")
+	}
+	return ""
+}
+
+// allowHeadersResponse 预先计算Access-Control-Allow-Headers的值，返回空字符串表示回显请求的头部
+func (c *Cors) allowHeadersResponse(style ResponseStyle) string {
+	denied := len(c.deniedHeaders) > 0 || len(c.deniedWHeaders) > 0
+	if style == ResponseWildcard {
+		// 浏览器会缓存"*"，存在禁止的头部时不能使用
+		if c.allowedHeadersAll && !c.allowCredentials && !denied {
+			// 通配符不包括Authorization，明确允许时需要单独列出
+			if matchHeader("Authorization", c.allowedHeaders, c.allowedWHeaders) {
+				return "*, Authorization"
+			}
+			return "*"
+		}
+		style = ResponseList
+	}
+	if style != ResponseList || c.allowedHeadersAll || len(c.allowedWHeaders) > 0 {
+		return ""
+	}
+	var list []string
+	for _, h := range c.allowedHeaders {
+		if !matchHeader(h, c.deniedHeaders, c.deniedWHeaders) {
+			list = append(list, h)
+		}
+	}
+	// 值不符合安全列表的Accept等头部也会出现在预检请求中，这些头部按名称总是被允许，因此需要一并列出
+	for _, h := range safelistedHeaderNames {
+		if !matchHeader(h, list, nil) && !matchHeader(h, c.deniedHeaders, c.deniedWHeaders) {
+			list = append(list, h)
+		}
+	}
+	return strings.Join(list, ", ")
+}
+
+// safelistedHeaderNames 是只按名称判断即属于安全列表的请求头部
+var safelistedHeaderNames = []string{"Accept", "Accept-Language", "Content-Language"}
@@ -0,0 +1,151 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestResponseStyle(t *testing.T) {
+	cases := []struct {
+		name    string
+		options Options
+		methods string
+		headers string
+	}{
+		{
+			"Echo",
+			Options{
+				AllowedMethods: []string{"GET", "PUT"},
+				AllowedHeaders: []string{"X-Header-1", "X-Header-2"},
+			},
+			"PUT",
+			"X-Header-1",
+		},
+		{
+			"List",
+			Options{
+				AllowedMethods:    []string{"GET", "PUT"},
+				AllowedHeaders:    []string{"X-Header-1", "X-Header-2"},
+				DeniedHeaders:     []string{"X-Header-2"},
+				AllowMethodsStyle: ResponseList,
+				AllowHeadersStyle: ResponseList,
+			},
+			"GET, PUT",
+			"Origin, X-Header-1, Accept, Accept-Language, Content-Language",
+		},
+		{
+			"ListFallsBackToEcho",
+			Options{
+				AllowedMethods:    []string{"*"},
+				AllowedHeaders:    []string{"X-Header-*"},
+				AllowMethodsStyle: ResponseList,
+				AllowHeadersStyle: ResponseList,
+			},
+			"PUT",
+			"X-Header-1",
+		},
+		{
+			"Wildcard",
+			Options{
+				AllowedMethods:    []string{"*"},
+				AllowedHeaders:    []string{"*"},
+				AllowMethodsStyle: ResponseWildcard,
+				AllowHeadersStyle: ResponseWildcard,
+			},
+			"*",
+			"*",
+		},
+		{
+			"WildcardWithAuthorization",
+			Options{
+				AllowedMethods:    []string{"*"},
+				AllowedHeaders:    []string{"*", "Authorization"},
+				AllowMethodsStyle: ResponseWildcard,
+				AllowHeadersStyle: ResponseWildcard,
+			},
+			"*",
+			"*, Authorization",
+		},
+		{
+			"WildcardWithCredentials",
+			Options{
+				AllowedMethods:    []string{"*"},
+				AllowedHeaders:    []string{"*"},
+				AllowCredentials:  true,
+				AllowMethodsStyle: ResponseWildcard,
+				AllowHeadersStyle: ResponseWildcard,
+			},
+			"PUT",
+			"X-Header-1",
+		},
+		{
+			"WildcardWithDeniedHeaders",
+			Options{
+				AllowedHeaders:    []string{"*"},
+				DeniedHeaders:     []string{"X-Internal-*"},
+				AllowHeadersStyle: ResponseWildcard,
+			},
+			"PUT",
+			"X-Header-1",
+		},
+		{
+			"WildcardFallsBackToList",
+			Options{
+				AllowedMethods:    []string{"GET", "PUT"},
+				AllowedHeaders:    []string{"X-Header-1"},
+				AllowMethodsStyle: ResponseWildcard,
+				AllowHeadersStyle: ResponseWildcard,
+			},
+			"GET, PUT",
+			"Origin, X-Header-1, Accept, Accept-Language, Content-Language",
+		},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			opts := tc.options
+			if opts.AllowedMethods == nil {
+				opts.AllowedMethods = []string{"PUT"}
+			}
+			s := New(opts)
+
+			req := newPreflight("http://foobar.com", "PUT")
+			req.Header.Set("Access-Control-Request-Headers", "X-Header-1")
+			res := httptest.NewRecorder()
+			s.Handler(testHandler).ServeHTTP(res, req)
+			assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Methods"), tc.methods)
+			assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Headers"), tc.headers)
+		})
+	}
+}
+
+func TestResponseStyleWithoutRequestedHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedHeaders:    []string{"X-Header-1"},
+		AllowHeadersStyle: ResponseList,
+	})
+	res := httptest.NewRecorder()
+	s.HandlerFunc(res, newPreflight("http://foobar.com", "GET"))
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Headers"), "Origin, X-Header-1, Accept, Accept-Language, Content-Language")
+	assert.DeepEqual(t, res.Code, http.StatusOK)
+}
+
+func TestResponseListSafelistedHeaders(t *testing.T) {
+	s := New(Options{
+		AllowedHeaders:    []string{"X-Header-1"},
+		DeniedHeaders:     []string{"Content-Language"},
+		AllowHeadersStyle: ResponseList,
+	})
+
+	// 值过长的Accept会出现在Access-Control-Request-Headers中，列表中必须包含它
+	req := newPreflight("http://foobar.com", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "accept,x-header-1")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Origin"), "*")
+	assert.DeepEqual(t, res.Header().Get("Access-Control-Allow-Headers"), "Origin, X-Header-1, Accept, Accept-Language")
+}
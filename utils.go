@@ -1,11 +1,11 @@
 package cors
 
 import (
+	"fmt"
+	"net/http"
 	"strings"
 )
 
-const toLower = 'a' - 'A'
-
 type converter func(string) string
 
 type wildcard struct {
@@ -17,6 +17,73 @@ func (w wildcard) match(s string) bool {
 	return len(s) >= len(w.prefix+w.suffix) && strings.HasPrefix(s, w.prefix) && strings.HasSuffix(s, w.suffix)
 }
 
+// parseOrigins 将源列表拆分为精确匹配的源和通配符源，列表中包含"*"时all为true
+func parseOrigins(origins []string) (exact []string, wildcards []wildcard, all bool) {
+	exact = []string{}
+	wildcards = []wildcard{}
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+		if origin == "*" {
+			return nil, nil, true
+		} else if i := strings.IndexByte(origin, '*'); i >= 0 {
+			wildcards = append(wildcards, wildcard{origin[:i], origin[i+1:]})
+		} else {
+			exact = append(exact, origin)
+		}
+	}
+	return exact, wildcards, false
+}
+
+func matchOrigin(origin string, exact []string, wildcards []wildcard) bool {
+	origin = strings.ToLower(origin)
+	for _, o := range exact {
+		if o == origin {
+			return true
+		}
+	}
+	for _, w := range wildcards {
+		if w.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHeaderPatterns 将头部列表拆分为精确匹配的头部和通配符模式，列表中包含"*"时all为true
+func parseHeaderPatterns(headers []string) (exact []string, wildcards []wildcard, all bool) {
+	exact = []string{}
+	for _, h := range headers {
+		h = strings.TrimSpace(h)
+		if h == "*" {
+			all = true
+		} else if i := strings.IndexByte(h, '*'); i >= 0 {
+			h = strings.ToLower(h)
+			wildcards = append(wildcards, wildcard{h[:i], h[i+1:]})
+		} else if h != "" {
+			exact = append(exact, http.CanonicalHeaderKey(h))
+		}
+	}
+	return exact, wildcards, all
+}
+
+// matchHeader 判断规范化的头部名称是否匹配精确的头部或者通配符模式
+func matchHeader(header string, exact []string, wildcards []wildcard) bool {
+	for _, h := range exact {
+		if h == header {
+			return true
+		}
+	}
+	if len(wildcards) > 0 {
+		header = strings.ToLower(header)
+		for _, w := range wildcards {
+			if w.match(header) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func convert(s []string, c converter) []string {
 	out := []string{}
 	for _, i := range s {
@@ -25,45 +92,62 @@ func convert(s []string, c converter) []string {
 	return out
 }
 
-func parseHeaderList(headerList string) []string {
-	l := len(headerList)
-	h := make([]byte, 0, l)
-	upper := true
-
-	t := 0
-	for i := 0; i < l; i++ {
-		if headerList[i] == ',' {
-			t++
-		}
-	}
-	headers := make([]string, 0, t)
-	for i := 0; i < l; i++ {
-		b := headerList[i]
-		if b >= 'a' && b <= 'z' {
-			if upper {
-				h = append(h, b-toLower)
+// parseHeaderList 按照RFC 7230的token列表语法解析Access-Control-Request-Headers，
+// 支持多个头部行，忽略空元素和元素两侧的空白，并将头部名称规范化。
+// 任何元素不是合法的token时返回错误
+func parseHeaderList(values []string) ([]string, error) {
+	var headers []string
+	for _, v := range values {
+		for len(v) > 0 {
+			var elem string
+			if i := strings.IndexByte(v, ','); i >= 0 {
+				elem, v = v[:i], v[i+1:]
 			} else {
-				h = append(h, b)
+				elem, v = v, ""
 			}
-		} else if b >= 'A' && b <= 'Z' {
-			if !upper {
-				h = append(h, b+toLower)
-			} else {
-				h = append(h, b)
+			elem = strings.Trim(elem, " \t")
+			if elem == "" {
+				continue
+			}
+			if !isToken(elem) {
+				return nil, fmt.Errorf("cors: invalid header name %q", elem)
 			}
-		} else if b == '-' || b == '_' || (b >= '0' && b <= '9') {
-			h = append(h, b)
+			headers = append(headers, http.CanonicalHeaderKey(elem))
+		}
+	}
+	return headers, nil
+}
+
+// isToken 判断s是否是RFC 7230定义的token
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			return false
 		}
+	}
+	return true
+}
 
-		if b == ' ' || b == ',' || i == l-1 {
-			if len(h) > 0 {
-				headers = append(headers, string(h))
-				h = h[:0]
-				upper = true
-			}
-		} else {
-			upper = b == '-' || b == '_'
+func isTokenChar(b byte) bool {
+	switch {
+	case b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z', b >= '0' && b <= '9':
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~", b) >= 0
+}
+
+// normalizeMethod 按照Fetch规范规范化方法，只有DELETE, GET, HEAD, OPTIONS, POST, PUT不区分大小写
+func normalizeMethod(method string) string {
+	for _, m := range []string{
+		http.MethodDelete, http.MethodGet, http.MethodHead,
+		http.MethodOptions, http.MethodPost, http.MethodPut,
+	} {
+		if strings.EqualFold(method, m) {
+			return m
 		}
 	}
-	return headers
+	return method
 }
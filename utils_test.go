@@ -21,22 +21,84 @@ func TestConvert(t *testing.T) {
 }
 
 func TestParseHeaderList(t *testing.T) {
-	h := parseHeaderList("header, second-header, THIRD-HEADER, Numb3r3d-H34d3r")
+	h, err := parseHeaderList([]string{"header, second-header, THIRD-HEADER, Numb3r3d-H34d3r"})
+	assert.Nil(t, err)
 	e := []string{"Header", "Second-Header", "Third-Header", "Numb3r3d-H34d3r"}
 	assert.DeepEqual(t, h, e)
 }
 
+func TestParseHeaderListTokens(t *testing.T) {
+	h, err := parseHeaderList([]string{"x-foo.bar,\tx-b!z , x_qux", "X-Second-Line"})
+	assert.Nil(t, err)
+	assert.DeepEqual(t, h, []string{"X-Foo.bar", "X-B!z", "X_qux", "X-Second-Line"})
+
+	for _, v := range []string{"x-foo bar", "x-foo@", "x-foo:", "\"x-foo\"", "x-f\u00f6o", "x-foo\x00"} {
+		_, err = parseHeaderList([]string{v})
+		assert.NotNil(t, err)
+	}
+}
+
 func TestParseHeaderListEmpty(t *testing.T) {
-	h1 := parseHeaderList("")
-	assert.Empty(t, h1)
+	h, err := parseHeaderList(nil)
+	assert.Nil(t, err)
+	assert.Empty(t, h)
 
-	h2 := parseHeaderList(", ")
-	assert.Empty(t, h2)
+	h, err = parseHeaderList([]string{"", ", ,\t,"})
+	assert.Nil(t, err)
+	assert.Empty(t, h)
+}
+
+func FuzzParseHeaderList(f *testing.F) {
+	f.Add("header, second-header")
+	f.Add(" , x-foo.bar,,\t")
+	f.Add("x-foo bar")
+	f.Fuzz(func(t *testing.T, value string) {
+		h, err := parseHeaderList([]string{value})
+		if err != nil {
+			return
+		}
+		for _, name := range h {
+			if !isToken(name) {
+				t.Fatalf("invalid header name %q", name)
+			}
+		}
+		again, err := parseHeaderList([]string{strings.Join(h, ",")})
+		if err != nil || strings.Join(again, ",") != strings.Join(h, ",") {
+			t.Fatalf("reparse mismatch: %q != %q (%v)", again, h, err)
+		}
+	})
 }
 
 func BenchmarkParseHeaderList(b *testing.B) {
 	b.ReportAllocs()
+	values := []string{"header, second-header, THIRD-HEADER"}
 	for i := 0; i < b.N; i++ {
-		parseHeaderList("header, second-header, THIRD-HEADER")
+		parseHeaderList(values)
 	}
 }
+
+func TestParseHeaderPatterns(t *testing.T) {
+	exact, wildcards, all := parseHeaderPatterns([]string{"x-header-1", "X-Amz-*", " ", "*-Token"})
+	assert.DeepEqual(t, exact, []string{"X-Header-1"})
+	assert.DeepEqual(t, wildcards, []wildcard{{"x-amz-", ""}, {"", "-token"}})
+	assert.False(t, all)
+
+	_, _, all = parseHeaderPatterns([]string{"X-Header-1", "*"})
+	assert.True(t, all)
+}
+
+func TestMatchHeader(t *testing.T) {
+	exact := []string{"X-Header-1"}
+	wildcards := []wildcard{{"x-amz-", ""}}
+	assert.True(t, matchHeader("X-Header-1", exact, wildcards))
+	assert.True(t, matchHeader("X-Amz-Date", exact, wildcards))
+	assert.False(t, matchHeader("X-Amzdate", exact, wildcards))
+	assert.False(t, matchHeader("X-Header-2", exact, wildcards))
+}
+
+func TestNormalizeMethod(t *testing.T) {
+	assert.DeepEqual(t, normalizeMethod("get"), "GET")
+	assert.DeepEqual(t, normalizeMethod("Delete"), "DELETE")
+	assert.DeepEqual(t, normalizeMethod("patch"), "patch")
+	assert.DeepEqual(t, normalizeMethod("PROPFIND"), "PROPFIND")
+}
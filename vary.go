@@ -0,0 +1,63 @@
+package cors
+
+import (
+	"net/http"
+	"strings"
+)
+
+// addVary 将values合并到响应的Vary头部。已有的多个Vary头部和逗号分隔的列表会被合并为一个，
+// 不区分大小写地去掉重复的值，任何一方包含"*"时只保留"*"
+func addVary(h http.Header, values ...string) {
+	if v := mergeVary(h.Values("Vary"), values); v != "" {
+		h.Set("Vary", v)
+	}
+}
+
+// mergeVary 合并多个Vary列表
+func mergeVary(lists ...[]string) string {
+	v := mergeList(lists...)
+	for _, elem := range strings.Split(v, ", ") {
+		if elem == "*" {
+			return "*"
+		}
+	}
+	return v
+}
+
+// preflightVary 返回预检请求的响应需要的Vary值。
+// 方法和头部不受限制并且响应中的值固定时，Access-Control-Request-Method和
+// Access-Control-Request-Headers不会影响响应，因此不需要加入Vary。
+// 格式无效的方法和头部仍然会被拒绝，但浏览器不会发送这样的预检请求
+func (c *Cors) preflightVary() []string {
+	vary := []string{"Origin"}
+	if !c.methodsUnrestricted {
+		vary = append(vary, "Access-Control-Request-Method")
+	}
+	if !c.headersUnrestricted {
+		vary = append(vary, "Access-Control-Request-Headers")
+	}
+	if c.privateNetwork {
+		vary = append(vary, "Access-Control-Request-Private-Network")
+	}
+	return vary
+}
+
+// mergeList 合并多个逗号分隔的列表，不区分大小写地去掉重复的元素
+func mergeList(lists ...[]string) string {
+	var out []string
+	seen := map[string]struct{}{}
+	for _, list := range lists {
+		for _, v := range list {
+			for _, elem := range strings.Split(v, ",") {
+				elem = strings.TrimSpace(elem)
+				key := strings.ToLower(elem)
+				if _, ok := seen[key]; ok || elem == "" {
+					continue
+				}
+				seen[key] = struct{}{}
+				out = append(out, elem)
+			}
+		}
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,97 @@
+package cors
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	addVary(h, "Origin")
+	assert.DeepEqual(t, h.Values("Vary"), []string{"Origin"})
+
+	h = http.Header{"Vary": {"Accept-Encoding, origin", "Accept"}}
+	addVary(h, "Origin", "Access-Control-Request-Method")
+	assert.DeepEqual(t, h.Values("Vary"), []string{"Accept-Encoding, origin, Accept, Access-Control-Request-Method"})
+
+	h = http.Header{"Vary": {"*"}}
+	addVary(h, "Origin")
+	assert.DeepEqual(t, h.Values("Vary"), []string{"*"})
+
+	h = http.Header{}
+	addVary(h)
+	assert.Empty(t, h.Values("Vary"))
+}
+
+func TestMergeList(t *testing.T) {
+	assert.DeepEqual(t, mergeList([]string{"A, b"}, []string{"B,c", " ,"}), "A, b, c")
+}
+
+func TestVaryNoDuplicates(t *testing.T) {
+	s := New(Options{AllowedOrigins: []string{"http://foobar.com"}})
+	handler := s.Handler(testHandler)
+
+	req, _ := http.NewRequest("GET", "http://example.com/foo", nil)
+	req.Header.Set("Origin", "http://foobar.com")
+	res := httptest.NewRecorder()
+	res.Header().Set("Vary", "Origin")
+	handler.ServeHTTP(res, req)
+	assert.DeepEqual(t, res.Header().Values("Vary"), []string{"Origin"})
+
+	res = httptest.NewRecorder()
+	res.Header().Add("Vary", "Accept-Encoding")
+	res.Header().Add("Vary", "Origin")
+	handler.ServeHTTP(res, newPreflight("http://foobar.com", "GET"))
+	assert.DeepEqual(t, res.Header().Values("Vary"),
+		[]string{"Accept-Encoding, Origin, Access-Control-Request-Method, Access-Control-Request-Headers"})
+}
+
+func TestPreflightVaryMinimized(t *testing.T) {
+	s := New(Options{
+		AllowedMethods:    []string{"*"},
+		AllowedHeaders:    []string{"*", "Authorization"},
+		AllowMethodsStyle: ResponseWildcard,
+		AllowHeadersStyle: ResponseWildcard,
+	})
+	req := newPreflight("http://foobar.com", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "X-Foo, Authorization")
+	res := httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, req)
+	assertHeaders(t, res.Header(), map[string]string{
+		"Vary":                         "Origin",
+		"Access-Control-Allow-Origin":  "*",
+		"Access-Control-Allow-Methods": "*",
+		"Access-Control-Allow-Headers": "*, Authorization",
+	})
+
+	// 只有方法不受限制时仍然需要Access-Control-Request-Headers
+	s = New(Options{
+		AllowedMethods:    []string{"*"},
+		AllowMethodsStyle: ResponseWildcard,
+	})
+	res = httptest.NewRecorder()
+	s.Handler(testHandler).ServeHTTP(res, newPreflight("http://foobar.com", "PUT"))
+	assert.DeepEqual(t, res.Header().Get("Vary"), "Origin, Access-Control-Request-Headers")
+}
+
+func TestPreflightUnrestrictedStillValidated(t *testing.T) {
+	s := New(Options{
+		AllowedMethods:    []string{"*"},
+		AllowedHeaders:    []string{"*"},
+		AllowMethodsStyle: ResponseWildcard,
+		AllowHeadersStyle: ResponseWildcard,
+	})
+
+	d := s.Evaluate(newPreflight("http://foobar.com", "BAD METHOD"))
+	assert.False(t, d.Allowed)
+	assert.DeepEqual(t, d.Reason, ReasonInvalidMethod)
+
+	req := newPreflight("http://foobar.com", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "X-Foo, X Bar")
+	d = s.Evaluate(req)
+	assert.False(t, d.Allowed)
+	assert.DeepEqual(t, d.Reason, ReasonInvalidHeaders)
+}
@@ -0,0 +1,118 @@
+package cors
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// hookWriter 包装http.ResponseWriter，在响应头部被发送之前按顺序调用一次hooks。
+// 交给处理器时应当使用wrap，它只暴露底层ResponseWriter实际支持的http.Flusher和http.Hijacker，
+// 并通过Unwrap支持http.ResponseController
+type hookWriter struct {
+	http.ResponseWriter
+	hooks     []func(h http.Header)
+	committed bool
+}
+
+func newHookWriter(w http.ResponseWriter, hooks ...func(h http.Header)) *hookWriter {
+	return &hookWriter{ResponseWriter: w, hooks: hooks}
+}
+
+// commit 在第一次发送响应头部之前调用hooks，处理器没有写入任何内容时也需要在返回后调用
+func (w *hookWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	headers := w.ResponseWriter.Header()
+	for _, hook := range w.hooks {
+		hook(headers)
+	}
+}
+
+func (w *hookWriter) WriteHeader(code int) {
+	// 1xx信息响应（101除外）之后还会发送最终的响应头部
+	if code < 100 || code > 199 || code == http.StatusSwitchingProtocols {
+		w.commit()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *hookWriter) Write(b []byte) (int, error) {
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+// wrap 返回交给处理器的ResponseWriter，它实现的可选接口与底层的ResponseWriter一致
+func (w *hookWriter) wrap() http.ResponseWriter {
+	_, flusher := w.ResponseWriter.(http.Flusher)
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{w}
+	case flusher:
+		return flushWriter{w}
+	case hijacker:
+		return hijackWriter{w}
+	}
+	return w
+}
+
+func (w *hookWriter) flush() {
+	w.commit()
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *hookWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	// 连接被接管后不再由net/http发送响应头部
+	w.committed = true
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+func (w *hookWriter) ReadFrom(src io.Reader) (int64, error) {
+	w.commit()
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		return rf.ReadFrom(src)
+	}
+	return io.Copy(writerOnly{w.ResponseWriter}, src)
+}
+
+// Unwrap 返回底层的ResponseWriter
+func (w *hookWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// writerOnly 隐藏ResponseWriter的ReadFrom方法，避免io.Copy递归调用
+type writerOnly struct {
+	io.Writer
+}
+
+type flushWriter struct {
+	*hookWriter
+}
+
+func (w flushWriter) Flush() {
+	w.flush()
+}
+
+type hijackWriter struct {
+	*hookWriter
+}
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
+
+type flushHijackWriter struct {
+	*hookWriter
+}
+
+func (w flushHijackWriter) Flush() {
+	w.flush()
+}
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijack()
+}
@@ -0,0 +1,115 @@
+package cors
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gotoxu/assert"
+)
+
+func TestHookWriterCommitOnce(t *testing.T) {
+	calls := 0
+	res := httptest.NewRecorder()
+	w := newHookWriter(res, func(h http.Header) {
+		calls++
+		h.Set("X-Hook", "1")
+	})
+
+	w.WriteHeader(http.StatusEarlyHints)
+	assert.DeepEqual(t, calls, 0)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("a"))
+	w.commit()
+	assert.DeepEqual(t, calls, 1)
+	assert.DeepEqual(t, res.Header().Get("X-Hook"), "1")
+}
+
+func TestHookWriterReadFrom(t *testing.T) {
+	res := httptest.NewRecorder()
+	w := newHookWriter(res, func(h http.Header) { h.Set("X-Hook", "1") })
+
+	n, err := io.Copy(w, strings.NewReader("hello"))
+	assert.Nil(t, err)
+	assert.DeepEqual(t, n, int64(5))
+	assert.DeepEqual(t, res.Body.String(), "hello")
+	assert.DeepEqual(t, res.Header().Get("X-Hook"), "1")
+}
+
+// plainWriter 隐藏ResponseRecorder的Flush方法
+type plainWriter struct {
+	http.ResponseWriter
+}
+
+// hijackWriterOnly 只支持http.Hijacker
+type hijackWriterOnly struct {
+	http.ResponseWriter
+}
+
+func (hijackWriterOnly) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+// hijackRecorder 在ResponseRecorder的基础上支持http.Hijacker
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return nil, nil, nil
+}
+
+func TestHookWriterInterfaces(t *testing.T) {
+	cases := []struct {
+		name     string
+		w        http.ResponseWriter
+		flusher  bool
+		hijacker bool
+	}{
+		{"Plain", plainWriter{httptest.NewRecorder()}, false, false},
+		{"Flusher", httptest.NewRecorder(), true, false},
+		{"Hijacker", hijackWriterOnly{httptest.NewRecorder()}, false, true},
+		{"FlusherHijacker", hijackRecorder{httptest.NewRecorder()}, true, true},
+	}
+
+	for i := range cases {
+		tc := cases[i]
+		t.Run(tc.name, func(t *testing.T) {
+			w := newHookWriter(tc.w).wrap()
+			_, ok := w.(http.Flusher)
+			assert.DeepEqual(t, ok, tc.flusher)
+			_, ok = w.(http.Hijacker)
+			assert.DeepEqual(t, ok, tc.hijacker)
+		})
+	}
+}
+
+func TestHookWriterHijack(t *testing.T) {
+	calls := 0
+	hw := newHookWriter(hijackRecorder{httptest.NewRecorder()}, func(h http.Header) { calls++ })
+	_, _, err := hw.wrap().(http.Hijacker).Hijack()
+	assert.Nil(t, err)
+	hw.commit()
+	assert.DeepEqual(t, calls, 0)
+}
+
+func TestHookWriterUnwrap(t *testing.T) {
+	res := httptest.NewRecorder()
+	hw := newHookWriter(res, func(h http.Header) { h.Set("X-Hook", "1") })
+	w := hw.wrap()
+	assert.DeepEqual(t, hw.Unwrap(), http.ResponseWriter(res))
+
+	assert.Nil(t, http.NewResponseController(w).Flush())
+	assert.True(t, res.Flushed)
+	assert.DeepEqual(t, res.Header().Get("X-Hook"), "1")
+
+	// 底层不支持的操作通过ResponseController返回错误
+	w = newHookWriter(plainWriter{httptest.NewRecorder()}).wrap()
+	assert.NotNil(t, http.NewResponseController(w).Flush())
+	_, _, err := http.NewResponseController(w).Hijack()
+	assert.NotNil(t, err)
+}